@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"strings"
+
+	"github.com/BurakYs/go-api-example/util/pagination"
+)
+
+type ListQuery struct {
+	pagination.Query
+
+	Action   string `query:"action"   validate:"omitempty,max=64"`
+	ActorID  string `query:"actorId"  validate:"omitempty,uuid"`
+	TargetID string `query:"targetId" validate:"omitempty,uuid"`
+}
+
+func (q *ListQuery) Normalize() {
+	q.Action = strings.TrimSpace(q.Action)
+	q.ActorID = strings.TrimSpace(q.ActorID)
+	q.TargetID = strings.TrimSpace(q.TargetID)
+}
+
+func (q *ListQuery) Filter() Filter {
+	return Filter{
+		Action:   q.Action,
+		ActorID:  q.ActorID,
+		TargetID: q.TargetID,
+	}
+}
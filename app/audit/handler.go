@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/middleware"
+	"github.com/BurakYs/go-api-example/util/pagination"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{
+		svc: svc,
+	}
+}
+
+func (h *Handler) List(c fiber.Ctx) error {
+	query, err := middleware.ValidateQuery[ListQuery](c)
+	if err != nil {
+		return err
+	}
+
+	entries, nextCursor, err := h.svc.List(c, query.Filter(), query.Query)
+	if err != nil {
+		return err
+	}
+
+	pagination.SetLinkHeader(c, nextCursor)
+	return c.JSON(pagination.NewResponse(entries, nextCursor))
+}
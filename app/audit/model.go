@@ -0,0 +1,26 @@
+package audit
+
+import "time"
+
+type Entry struct {
+	ID        string         `json:"id"                 bson:"_id"`
+	TenantID  string         `json:"-"                  bson:"tenant_id"`
+	Action    string         `json:"action"             bson:"action"`
+	ActorID   string         `json:"actorId"            bson:"actor_id"`
+	TargetID  string         `json:"targetId,omitempty" bson:"target_id,omitempty"`
+	IP        string         `json:"ip,omitempty"       bson:"ip,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"          bson:"created_at"`
+}
+
+const (
+	ActionImpersonationStart = "impersonation.start"
+	ActionImpersonationStop  = "impersonation.stop"
+)
+
+// Filter narrows down audit log listings. Zero values are ignored.
+type Filter struct {
+	Action   string
+	ActorID  string
+	TargetID string
+}
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/BurakYs/go-api-example/database"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/tenant"
+)
+
+type Repository struct {
+	collection *mongo.Collection
+}
+
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{
+		collection: db.GetCollection("audit_logs"),
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, entry *Entry) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	entry.TenantID = tenantID
+
+	_, err = r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+func (r *Repository) List(ctx context.Context, filter Filter, page pagination.Query) ([]*Entry, string, error) {
+	query := bson.M{}
+
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+
+	if filter.ActorID != "" {
+		query["actor_id"] = filter.ActorID
+	}
+
+	if filter.TargetID != "" {
+		query["target_id"] = filter.TargetID
+	}
+
+	query, err := tenant.Scope(ctx, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pagination.Find[Entry](ctx, r.collection, query, page, pagination.NewestFirst)
+}
+
+func (r *Repository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "created_at", Value: -1},
+				bson.E{Key: "_id", Value: -1},
+			),
+			Options: options.Index().SetName("tenant_created_at_index"),
+		},
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "actor_id", Value: 1},
+				bson.E{Key: "created_at", Value: -1},
+			),
+			Options: options.Index().SetName("tenant_actor_id_index"),
+		},
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "target_id", Value: 1},
+				bson.E{Key: "created_at", Value: -1},
+			),
+			Options: options.Index().SetName("tenant_target_id_index"),
+		},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return err
+	}
+
+	return database.DropIndexes(ctx, r.collection, "created_at_index", "actor_id_index", "target_id_index")
+}
+
+// BackfillTenant assigns entries recorded before tenancy to tenantID.
+func (r *Repository) BackfillTenant(ctx context.Context, tenantID string) error {
+	filter := bson.M{"tenant_id": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"tenant_id": tenantID}}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
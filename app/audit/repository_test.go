@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BurakYs/go-api-example/database/dbtest"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/tenant"
+)
+
+func TestRepositoryTenantIsolation(t *testing.T) {
+	repo := NewRepository(dbtest.New(t))
+	ctx := context.Background()
+
+	err := repo.CreateIndexes(ctx)
+	if err != nil {
+		t.Fatalf("CreateIndexes: %v", err)
+	}
+
+	// Both tenants log the same action by the same actor, so only the tenant
+	// tells the entries apart.
+	for _, tenantID := range []string{"a", "b"} {
+		err = repo.Create(tenant.NewContext(ctx, tenantID), &Entry{
+			ID:        "entry-" + tenantID,
+			Action:    ActionImpersonationStart,
+			ActorID:   "actor",
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Create in tenant %q: %v", tenantID, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		tenant  string
+		filter  Filter
+		wantIDs []string
+		wantErr error
+	}{
+		{name: "tenant a", tenant: "a", wantIDs: []string{"entry-a"}},
+		{name: "tenant b", tenant: "b", wantIDs: []string{"entry-b"}},
+		{name: "filtered by actor", tenant: "a", filter: Filter{ActorID: "actor"}, wantIDs: []string{"entry-a"}},
+		{name: "unknown tenant", tenant: "c"},
+		{name: "no tenant", wantErr: tenant.ErrMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = tenant.NewContext(ctx, tt.tenant)
+			}
+
+			entries, _, err := repo.List(ctx, tt.filter, pagination.Query{})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != len(tt.wantIDs) {
+				t.Fatalf("got %d entries, want %d", len(entries), len(tt.wantIDs))
+			}
+
+			for i, entry := range entries {
+				if entry.ID != tt.wantIDs[i] || entry.TenantID != tt.tenant {
+					t.Fatalf("got entry %q of tenant %q, want %q of tenant %q", entry.ID, entry.TenantID, tt.wantIDs[i], tt.tenant)
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoryCreateWithoutTenant(t *testing.T) {
+	repo := NewRepository(dbtest.New(t))
+
+	err := repo.Create(context.Background(), &Entry{ID: "entry", Action: ActionImpersonationStart})
+	if !errors.Is(err, tenant.ErrMissing) {
+		t.Fatalf("got error %v, want %v", err, tenant.ErrMissing)
+	}
+}
@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/BurakYs/go-api-example/util/pagination"
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{
+		repo: repo,
+	}
+}
+
+// Record stores the entry, filling in its ID and creation time.
+func (s *Service) Record(ctx context.Context, entry *Entry) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	entry.ID = id.String()
+	entry.CreatedAt = time.Now()
+
+	return s.repo.Create(ctx, entry)
+}
+
+func (s *Service) List(ctx context.Context, filter Filter, page pagination.Query) ([]*Entry, string, error) {
+	return s.repo.List(ctx, filter, page)
+}
@@ -0,0 +1,36 @@
+package invite
+
+import (
+	"strings"
+
+	"github.com/BurakYs/go-api-example/util/pagination"
+)
+
+type IssueBody struct {
+	MaxUses int    `json:"maxUses" validate:"required,min=1,max=10000"`
+	Note    string `json:"note"    validate:"omitempty,max=256"`
+
+	// ExpiresIn is in seconds. Invites without it never expire.
+	ExpiresIn int `json:"expiresIn" validate:"omitempty,min=1"`
+}
+
+func (b *IssueBody) Normalize() {
+	b.Note = strings.TrimSpace(b.Note)
+}
+
+type IDParams struct {
+	ID string `uri:"id" validate:"required,uuid"`
+}
+
+type ListQuery struct {
+	pagination.Query
+}
+
+type InviteResponse struct {
+	*Invite
+
+	// Code and Token are only returned when the invite is issued. Token is
+	// meant for invite links and is empty unless signing is configured.
+	Code  string `json:"code,omitempty"`
+	Token string `json:"token,omitempty"`
+}
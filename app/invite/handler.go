@@ -0,0 +1,70 @@
+package invite
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/middleware"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{
+		svc: svc,
+	}
+}
+
+func (h *Handler) Issue(c fiber.Ctx) error {
+	body, err := middleware.ValidateBody[IssueBody](c)
+	if err != nil {
+		return err
+	}
+
+	expiresIn := time.Duration(body.ExpiresIn) * time.Second
+
+	invite, code, token, err := h.svc.Issue(c, rctx.GetUserID(c), body.MaxUses, expiresIn, body.Note)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(InviteResponse{
+		Invite: invite,
+		Code:   code,
+		Token:  token,
+	})
+}
+
+func (h *Handler) List(c fiber.Ctx) error {
+	query, err := middleware.ValidateQuery[ListQuery](c)
+	if err != nil {
+		return err
+	}
+
+	invites, nextCursor, err := h.svc.List(c, query.Query)
+	if err != nil {
+		return err
+	}
+
+	pagination.SetLinkHeader(c, nextCursor)
+	return c.JSON(pagination.NewResponse(invites, nextCursor))
+}
+
+func (h *Handler) Revoke(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[IDParams](c)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.Revoke(c, params.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,28 @@
+package invite
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/httperror"
+)
+
+// Invite lets up to MaxUses people register while registration is invite
+// only. Invites without an expiration stay valid until revoked.
+type Invite struct {
+	ID        string    `json:"id"                 bson:"_id"`
+	TenantID  string    `json:"-"                  bson:"tenant_id"`
+	CodeHash  string    `json:"-"                  bson:"code_hash"`
+	Note      string    `json:"note,omitempty"     bson:"note,omitempty"`
+	MaxUses   int       `json:"maxUses"            bson:"max_uses"`
+	Uses      int       `json:"uses"               bson:"uses"`
+	CreatedBy string    `json:"createdBy"          bson:"created_by"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero" bson:"expires_at,omitempty"`
+	CreatedAt time.Time `json:"createdAt"          bson:"created_at"`
+}
+
+var (
+	ErrNotFound      = httperror.New(fiber.StatusNotFound, "Invite not found")
+	ErrInviteInvalid = httperror.New(fiber.StatusForbidden, "Invalid, expired or used up invite")
+)
@@ -0,0 +1,132 @@
+package invite
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/BurakYs/go-api-example/database"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/tenant"
+)
+
+type Repository struct {
+	collection *mongo.Collection
+}
+
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{
+		collection: db.GetCollection("invites"),
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, invite *Invite) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	invite.TenantID = tenantID
+
+	_, err = r.collection.InsertOne(ctx, invite)
+	return err
+}
+
+func (r *Repository) List(ctx context.Context, page pagination.Query) ([]*Invite, string, error) {
+	filter, err := tenant.Scope(ctx, bson.M{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pagination.Find[Invite](ctx, r.collection, filter, page, pagination.NewestFirst)
+}
+
+// Use atomically counts one use of the invite matching filter, as long as it
+// has uses left and has not expired.
+func (r *Repository) Use(ctx context.Context, filter bson.M) (*Invite, error) {
+	filter, err := tenant.Scope(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	filter["$expr"] = bson.M{"$lt": bson.A{"$uses", "$max_uses"}}
+	filter["$or"] = bson.A{
+		bson.M{"expires_at": bson.M{"$exists": false}},
+		bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+	}
+
+	var invite Invite
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"uses": 1}}, opts).Decode(&invite)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInviteInvalid
+		}
+		return nil, err
+	}
+
+	return &invite, nil
+}
+
+// Release gives back a use taken by Use, e.g. when the registration it was
+// taken for failed.
+func (r *Repository) Release(ctx context.Context, id string) error {
+	filter, err := tenant.Scope(ctx, bson.M{"_id": id, "uses": bson.M{"$gt": 0}})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"uses": -1}})
+	return err
+}
+
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	filter, err := tenant.Scope(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "code_hash", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("code_hash_index"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "tenant_id", Value: 1},
+				{Key: "created_at", Value: -1},
+				{Key: "_id", Value: -1},
+			},
+			Options: options.Index().SetName("tenant_created_at_index"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "expires_at", Value: 1},
+			},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_index"),
+		},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
@@ -0,0 +1,175 @@
+package invite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/BurakYs/go-api-example/database/dbtest"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/tenant"
+)
+
+// newTenantRepository returns a repository holding an unused invite
+// "invite-<tenant>" with the code hash "code-<tenant>" in tenants "a" and
+// "b".
+func newTenantRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	repo := NewRepository(dbtest.New(t))
+	ctx := context.Background()
+
+	err := repo.CreateIndexes(ctx)
+	if err != nil {
+		t.Fatalf("CreateIndexes: %v", err)
+	}
+
+	for _, tenantID := range []string{"a", "b"} {
+		err = repo.Create(tenant.NewContext(ctx, tenantID), &Invite{
+			ID:        "invite-" + tenantID,
+			CodeHash:  "code-" + tenantID,
+			MaxUses:   1,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Create in tenant %q: %v", tenantID, err)
+		}
+	}
+
+	return repo
+}
+
+func TestRepositoryUseTenantIsolation(t *testing.T) {
+	tests := []struct {
+		name    string
+		tenant  string
+		filter  bson.M
+		wantID  string
+		wantErr error
+	}{
+		{name: "code in own tenant", tenant: "a", filter: bson.M{"code_hash": "code-a"}, wantID: "invite-a"},
+		{name: "id in own tenant", tenant: "b", filter: bson.M{"_id": "invite-b"}, wantID: "invite-b"},
+		{name: "code of other tenant", tenant: "a", filter: bson.M{"code_hash": "code-b"}, wantErr: ErrInviteInvalid},
+		{name: "id of other tenant", tenant: "a", filter: bson.M{"_id": "invite-b"}, wantErr: ErrInviteInvalid},
+		{name: "filter naming other tenant", tenant: "a", filter: bson.M{"tenant_id": "b", "_id": "invite-b"}, wantErr: ErrInviteInvalid},
+		{name: "no tenant", filter: bson.M{"code_hash": "code-a"}, wantErr: tenant.ErrMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTenantRepository(t)
+
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = tenant.NewContext(ctx, tt.tenant)
+			}
+
+			invite, err := repo.Use(ctx, tt.filter)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if invite.ID != tt.wantID || invite.TenantID != tt.tenant || invite.Uses != 1 {
+				t.Fatalf("got invite %q of tenant %q with %d uses, want %q of tenant %q with 1", invite.ID, invite.TenantID, invite.Uses, tt.wantID, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestRepositoryListTenantIsolation(t *testing.T) {
+	repo := newTenantRepository(t)
+
+	tests := []struct {
+		name    string
+		tenant  string
+		wantIDs []string
+		wantErr error
+	}{
+		{name: "tenant a", tenant: "a", wantIDs: []string{"invite-a"}},
+		{name: "tenant b", tenant: "b", wantIDs: []string{"invite-b"}},
+		{name: "unknown tenant", tenant: "c"},
+		{name: "no tenant", wantErr: tenant.ErrMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = tenant.NewContext(ctx, tt.tenant)
+			}
+
+			invites, _, err := repo.List(ctx, pagination.Query{})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(invites) != len(tt.wantIDs) {
+				t.Fatalf("got %d invites, want %d", len(invites), len(tt.wantIDs))
+			}
+
+			for i, invite := range invites {
+				if invite.ID != tt.wantIDs[i] {
+					t.Fatalf("got invite %q, want %q", invite.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+// TestRepositoryModifyOtherTenant checks that writes naming the invite of
+// tenant "b" don't reach it from tenant "a".
+func TestRepositoryModifyOtherTenant(t *testing.T) {
+	repo := newTenantRepository(t)
+	ctxA := tenant.NewContext(context.Background(), "a")
+	ctxB := tenant.NewContext(context.Background(), "b")
+
+	_, err := repo.Use(ctxB, bson.M{"_id": "invite-b"})
+	if err != nil {
+		t.Fatalf("Use in tenant \"b\": %v", err)
+	}
+
+	err = repo.Release(ctxA, "invite-b")
+	if err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	err = repo.Delete(ctxA, "invite-b")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrNotFound)
+	}
+
+	invites, _, err := repo.List(ctxB, pagination.Query{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	if len(invites) != 1 || invites[0].Uses != 1 {
+		t.Fatalf("got %d invites, want invite-b with its use kept", len(invites))
+	}
+}
+
+func TestRepositoryCreateWithoutTenant(t *testing.T) {
+	repo := NewRepository(dbtest.New(t))
+
+	err := repo.Create(context.Background(), &Invite{ID: "invite", CodeHash: "code"})
+	if !errors.Is(err, tenant.ErrMissing) {
+		t.Fatalf("got error %v, want %v", err, tenant.ErrMissing)
+	}
+}
@@ -0,0 +1,110 @@
+package invite
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/BurakYs/go-api-example/util/pagination"
+)
+
+type Service struct {
+	repo   *Repository
+	secret []byte
+}
+
+// NewService creates an invite service. Signed invite links are only issued
+// and accepted when secret is not empty.
+func NewService(repo *Repository, secret string) *Service {
+	return &Service{
+		repo:   repo,
+		secret: []byte(secret),
+	}
+}
+
+// Issue creates an invite and returns it along with its code and, if
+// signing is configured, a token for invite links. Neither is stored in
+// plain text, so they can't be shown again later.
+func (s *Service) Issue(ctx context.Context, createdBy string, maxUses int, expiresIn time.Duration, note string) (*Invite, string, string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	bytes := make([]byte, 16)
+	_, _ = rand.Read(bytes)
+	code := hex.EncodeToString(bytes)
+
+	now := time.Now()
+	invite := &Invite{
+		ID:        id.String(),
+		CodeHash:  hashCode(code),
+		Note:      note,
+		MaxUses:   maxUses,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+	}
+
+	if expiresIn > 0 {
+		invite.ExpiresAt = now.Add(expiresIn)
+	}
+
+	err = s.repo.Create(ctx, invite)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	var token string
+	if len(s.secret) > 0 {
+		token = invite.ID + "." + s.sign(invite.ID)
+	}
+
+	return invite, code, token, nil
+}
+
+func (s *Service) List(ctx context.Context, page pagination.Query) ([]*Invite, string, error) {
+	return s.repo.List(ctx, page)
+}
+
+func (s *Service) Revoke(ctx context.Context, id string) error {
+	return s.repo.Delete(ctx, id)
+}
+
+// Use takes one use of the invite identified by an invite code or a signed
+// link token.
+func (s *Service) Use(ctx context.Context, codeOrToken string) (*Invite, error) {
+	id, signature, isToken := strings.Cut(codeOrToken, ".")
+	if !isToken {
+		return s.repo.Use(ctx, bson.M{"code_hash": hashCode(codeOrToken)})
+	}
+
+	if len(s.secret) == 0 || !hmac.Equal([]byte(signature), []byte(s.sign(id))) {
+		return nil, ErrInviteInvalid
+	}
+
+	return s.repo.Use(ctx, bson.M{"_id": id})
+}
+
+// Release gives back a use taken by Use.
+func (s *Service) Release(ctx context.Context, id string) error {
+	return s.repo.Release(ctx, id)
+}
+
+func (s *Service) sign(id string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,34 @@
+package ipaccess
+
+import "strings"
+
+type BanBody struct {
+	IP     string `json:"ip"     validate:"required,ip"`
+	Reason string `json:"reason" validate:"omitempty,max=256"`
+
+	// Duration is in seconds.
+	Duration int `json:"duration" validate:"required,min=1,max=31536000"`
+}
+
+func (b *BanBody) Normalize() {
+	b.IP = strings.TrimSpace(b.IP)
+	b.Reason = strings.TrimSpace(b.Reason)
+}
+
+type IPParams struct {
+	IP string `uri:"ip" validate:"required,ip"`
+}
+
+type RuleBody struct {
+	List   string `json:"list"   validate:"required,oneof=allow deny"`
+	Prefix string `json:"prefix" validate:"required,ip|cidr"`
+}
+
+func (b *RuleBody) Normalize() {
+	b.Prefix = strings.TrimSpace(b.Prefix)
+}
+
+type RuleQuery struct {
+	List   string `query:"list"   validate:"required,oneof=allow deny"`
+	Prefix string `query:"prefix" validate:"required,ip|cidr"`
+}
@@ -0,0 +1,157 @@
+package ipaccess
+
+import (
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/middleware"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{
+		svc: svc,
+	}
+}
+
+// Enforce rejects requests from denied and banned IPs and marks allowed ones
+// so IP based rate limits skip them. It must run after the client IP is
+// resolved.
+func (h *Handler) Enforce() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		access, remaining, err := h.svc.Check(c, rctx.GetClientIP(c))
+		if err != nil {
+			return err
+		}
+
+		switch access {
+		case AccessAllowed:
+			rctx.SetIPAllowed(c, true)
+		case AccessDenied:
+			return ErrDenied
+		case AccessBanned:
+			retryAfter := int64((remaining + time.Second - 1) / time.Second)
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+			return ErrBanned
+		}
+
+		return c.Next()
+	}
+}
+
+func (h *Handler) ListBans(c fiber.Ctx) error {
+	bans, err := h.svc.ListBans(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(pagination.NewResponse(bans, ""))
+}
+
+func (h *Handler) Ban(c fiber.Ctx) error {
+	body, err := middleware.ValidateBody[BanBody](c)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Duration(body.Duration) * time.Second
+
+	ban, err := h.svc.Ban(c, body.IP, duration, body.Reason, rctx.GetUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(ban)
+}
+
+func (h *Handler) Unban(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[IPParams](c)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.Unban(c, params.IP)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) ListRules(c fiber.Ctx) error {
+	rules, err := h.svc.ListRules(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(pagination.NewResponse(rules, ""))
+}
+
+func (h *Handler) AddRule(c fiber.Ctx) error {
+	body, err := middleware.ValidateBody[RuleBody](c)
+	if err != nil {
+		return err
+	}
+
+	if body.List == ListDeny {
+		err = checkLockout(body.Prefix, rctx.GetClientIP(c))
+		if err != nil {
+			return err
+		}
+	}
+
+	rule, err := h.svc.AddRule(c, body.List, body.Prefix)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// checkLockout rejects deny rules covering every address or the admin adding
+// them, since either would lock the admin out of the API.
+func checkLockout(value, clientIP string) error {
+	prefix, err := ParsePrefix(value)
+	if err != nil {
+		return nil
+	}
+
+	message := ""
+	if prefix.Bits() == 0 {
+		message = "Denying every address is not allowed"
+	} else if addr, err := netip.ParseAddr(clientIP); err == nil && prefix.Contains(addr.Unmap()) {
+		message = "Denying your own IP address is not allowed"
+	}
+
+	if message == "" {
+		return nil
+	}
+
+	return middleware.NewValidationError(middleware.ValidationFailure{
+		Location: "body",
+		Field:    "prefix",
+		Message:  message,
+	})
+}
+
+func (h *Handler) RemoveRule(c fiber.Ctx) error {
+	query, err := middleware.ValidateQuery[RuleQuery](c)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.RemoveRule(c, query.List, query.Prefix)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,7 @@
+-- Counts a rate limit rejection, starting the window with the first one.
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+  redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+
+return count
@@ -0,0 +1,51 @@
+package ipaccess
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/httperror"
+)
+
+// Ban blocks an IP until ExpiresAt. Bans issued automatically for exceeding
+// rate limits have no CreatedBy.
+type Ban struct {
+	IP        string    `json:"ip"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Rule adds an IP or CIDR range to a list. Addresses on the allow list are
+// never blocked and skip IP based rate limits, while those on the deny list
+// are always blocked.
+type Rule struct {
+	List   string `json:"list"`
+	Prefix string `json:"prefix"`
+}
+
+const (
+	ListAllow = "allow"
+	ListDeny  = "deny"
+)
+
+// Access is the outcome of checking a client's IP.
+type Access int
+
+const (
+	AccessDefault Access = iota
+	AccessAllowed
+	AccessDenied
+	AccessBanned
+)
+
+const autoBanReason = "Repeatedly exceeded rate limits"
+
+var (
+	ErrBanNotFound  = httperror.New(fiber.StatusNotFound, "Ban not found")
+	ErrRuleNotFound = httperror.New(fiber.StatusNotFound, "Rule not found")
+	ErrDenied       = httperror.New(fiber.StatusForbidden, "Access denied")
+	ErrBanned       = httperror.New(fiber.StatusForbidden, "Your IP address is temporarily banned")
+)
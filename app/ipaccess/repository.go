@@ -0,0 +1,161 @@
+package ipaccess
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/BurakYs/go-api-example/database"
+)
+
+var (
+	//go:embed incr_offenses.lua
+	incrOffensesScriptContent string
+
+	incrOffensesScript = redis.NewScript(incrOffensesScriptContent)
+)
+
+type Repository struct {
+	redis *database.Redis
+}
+
+func NewRepository(redis *database.Redis) *Repository {
+	return &Repository{
+		redis: redis,
+	}
+}
+
+func (r *Repository) Rules(ctx context.Context, list string) ([]string, error) {
+	return r.redis.Client().SMembers(ctx, r.listKey(list)).Result()
+}
+
+func (r *Repository) AddRule(ctx context.Context, list, prefix string) error {
+	return r.redis.Client().SAdd(ctx, r.listKey(list), prefix).Err()
+}
+
+func (r *Repository) RemoveRule(ctx context.Context, list, prefix string) error {
+	removed, err := r.redis.Client().SRem(ctx, r.listKey(list), prefix).Result()
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return ErrRuleNotFound
+	}
+
+	return nil
+}
+
+// Ban stores the ban under a key expiring with it, and indexes it by expiry
+// so active bans can be listed without scanning the keyspace.
+func (r *Repository) Ban(ctx context.Context, ban *Ban) error {
+	data, err := json.Marshal(ban)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.redis.Client().TxPipeline()
+	pipe.Set(ctx, r.banKey(ban.IP), data, time.Until(ban.ExpiresAt))
+	pipe.ZAdd(ctx, r.banIndexKey(), redis.Z{Score: float64(ban.ExpiresAt.UnixMilli()), Member: ban.IP})
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// BanRemaining returns how long the IP stays banned, or zero if it isn't.
+func (r *Repository) BanRemaining(ctx context.Context, ip string) (time.Duration, error) {
+	ttl, err := r.redis.Client().PTTL(ctx, r.banKey(ip)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	return max(ttl, 0), nil
+}
+
+func (r *Repository) ListBans(ctx context.Context) ([]*Ban, error) {
+	client := r.redis.Client()
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	err := client.ZRemRangeByScore(ctx, r.banIndexKey(), "-inf", now).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := client.ZRange(ctx, r.banIndexKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	bans := make([]*Ban, 0, len(ips))
+	if len(ips) == 0 {
+		return bans, nil
+	}
+
+	keys := make([]string, len(ips))
+	for i, ip := range ips {
+		keys[i] = r.banKey(ip)
+	}
+
+	values, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		// Bans lifted or expired since the index was read are skipped.
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var ban Ban
+		err = json.Unmarshal([]byte(data), &ban)
+		if err != nil {
+			return nil, err
+		}
+
+		bans = append(bans, &ban)
+	}
+
+	return bans, nil
+}
+
+func (r *Repository) Unban(ctx context.Context, ip string) error {
+	pipe := r.redis.Client().TxPipeline()
+	deleted := pipe.Del(ctx, r.banKey(ip))
+	pipe.ZRem(ctx, r.banIndexKey(), ip)
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if deleted.Val() == 0 {
+		return ErrBanNotFound
+	}
+
+	return nil
+}
+
+// IncrOffenses counts a rate limit rejection of the IP, returning the number
+// of rejections within window of the first one. The counter is created and
+// given its expiry in one script, so it can't be left without one.
+func (r *Repository) IncrOffenses(ctx context.Context, ip string, window time.Duration) (int64, error) {
+	return incrOffensesScript.Run(ctx, r.redis.Client(), []string{r.offensesKey(ip)}, window.Milliseconds()).Int64()
+}
+
+func (r *Repository) ClearOffenses(ctx context.Context, ip string) error {
+	return r.redis.Del(ctx, r.offensesKey(ip))
+}
+
+func (r *Repository) listKey(list string) string { return "ip_access:" + list }
+
+func (r *Repository) banKey(ip string) string { return "ip_ban:" + ip }
+
+func (r *Repository) banIndexKey() string { return "ip_bans" }
+
+func (r *Repository) offensesKey(ip string) string { return "ip_offenses:" + ip }
@@ -0,0 +1,342 @@
+package ipaccess
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/BurakYs/go-api-example/config"
+)
+
+type Service struct {
+	repo   *Repository
+	cfg    *config.IPAccessConfig
+	logger *zap.Logger
+
+	// Rules are checked on every request, so they are cached and reloaded
+	// from Redis once cfg.RefreshInterval has passed. Only one request
+	// reloads them at a time, without holding mu.
+	mu         sync.RWMutex
+	allow      []netip.Prefix
+	deny       []netip.Prefix
+	loaded     bool
+	loadedAt   time.Time
+	generation uint64
+	reload     *reloadCall
+
+	banLookupDown atomic.Bool
+}
+
+// reloadCall is a reload of the rules in progress. done is closed once it
+// finished, with err set if it failed.
+type reloadCall struct {
+	done chan struct{}
+	err  error
+}
+
+func NewService(repo *Repository, cfg *config.IPAccessConfig, logger *zap.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// Check decides whether requests from ip are let through. The allow list
+// wins over the deny list and bans. For banned IPs it also returns how long
+// the ban lasts.
+func (s *Service) Check(ctx context.Context, ip string) (Access, time.Duration, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return AccessDefault, 0, nil
+	}
+	addr = addr.Unmap()
+
+	allow, deny, err := s.rules(ctx)
+	if err != nil {
+		return AccessDefault, 0, err
+	}
+
+	if containsAddr(allow, addr) {
+		return AccessAllowed, 0, nil
+	}
+
+	if containsAddr(deny, addr) {
+		return AccessDenied, 0, nil
+	}
+
+	// Bans are a defense against abuse rather than access control, so while
+	// they can't be looked up requests go through as if there were none.
+	remaining, err := s.repo.BanRemaining(ctx, addr.String())
+	if err != nil {
+		if !s.banLookupDown.Swap(true) {
+			s.logger.Error("Failed to look up IP bans, letting requests through", zap.Error(err))
+		}
+		return AccessDefault, 0, nil
+	}
+
+	if s.banLookupDown.Swap(false) {
+		s.logger.Info("IP ban lookups recovered")
+	}
+
+	if remaining > 0 {
+		return AccessBanned, remaining, nil
+	}
+
+	return AccessDefault, 0, nil
+}
+
+// RecordOffense counts a rate limit rejection of ip and bans it once it was
+// rejected cfg.BanThreshold times within cfg.BanWindow.
+func (s *Service) RecordOffense(ctx context.Context, ip string) error {
+	if s.cfg.BanThreshold <= 0 {
+		return nil
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	ip = addr.Unmap().String()
+
+	count, err := s.repo.IncrOffenses(ctx, ip, s.cfg.BanWindow)
+	if err != nil {
+		return err
+	}
+
+	if count < int64(s.cfg.BanThreshold) {
+		return nil
+	}
+
+	_, err = s.Ban(ctx, ip, s.cfg.BanDuration, autoBanReason, "")
+	if err != nil {
+		return err
+	}
+
+	return s.repo.ClearOffenses(ctx, ip)
+}
+
+func (s *Service) Ban(ctx context.Context, ip string, duration time.Duration, reason, createdBy string) (*Ban, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP %q: %w", ip, err)
+	}
+
+	now := time.Now()
+	ban := &Ban{
+		IP:        addr.Unmap().String(),
+		Reason:    reason,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(duration),
+	}
+
+	err = s.repo.Ban(ctx, ban)
+	if err != nil {
+		return nil, err
+	}
+
+	return ban, nil
+}
+
+func (s *Service) Unban(ctx context.Context, ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ErrBanNotFound
+	}
+
+	err = s.repo.Unban(ctx, addr.Unmap().String())
+	if err != nil {
+		return err
+	}
+
+	return s.repo.ClearOffenses(ctx, addr.Unmap().String())
+}
+
+func (s *Service) ListBans(ctx context.Context) ([]*Ban, error) {
+	return s.repo.ListBans(ctx)
+}
+
+func (s *Service) ListRules(ctx context.Context) ([]*Rule, error) {
+	rules := make([]*Rule, 0)
+
+	for _, list := range []string{ListAllow, ListDeny} {
+		prefixes, err := s.repo.Rules(ctx, list)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, prefix := range prefixes {
+			rules = append(rules, &Rule{List: list, Prefix: prefix})
+		}
+	}
+
+	return rules, nil
+}
+
+func (s *Service) AddRule(ctx context.Context, list, value string) (*Rule, error) {
+	prefix, err := ParsePrefix(value)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &Rule{List: list, Prefix: prefix.String()}
+
+	err = s.repo.AddRule(ctx, list, rule.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return rule, nil
+}
+
+func (s *Service) RemoveRule(ctx context.Context, list, value string) error {
+	prefix, err := ParsePrefix(value)
+	if err != nil {
+		return ErrRuleNotFound
+	}
+
+	err = s.repo.RemoveRule(ctx, list, prefix.String())
+	if err != nil {
+		return err
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// rules returns the cached lists, reloading them when stale. If reloading
+// fails the stale lists are kept rather than failing every request.
+// Requests arriving during a reload keep using the stale lists, and only
+// wait for it when there are none yet.
+func (s *Service) rules(ctx context.Context) ([]netip.Prefix, []netip.Prefix, error) {
+	s.mu.RLock()
+	allow, deny, loaded, loadedAt := s.allow, s.deny, s.loaded, s.loadedAt
+	s.mu.RUnlock()
+
+	if time.Since(loadedAt) < s.cfg.RefreshInterval {
+		return allow, deny, nil
+	}
+
+	s.mu.Lock()
+	call := s.reload
+	leader := call == nil
+	if leader {
+		call = &reloadCall{done: make(chan struct{})}
+		s.reload = call
+	}
+	generation := s.generation
+	s.mu.Unlock()
+
+	if !leader {
+		if loaded {
+			return allow, deny, nil
+		}
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		if !s.loaded {
+			return nil, nil, call.err
+		}
+
+		return s.allow, s.deny, nil
+	}
+
+	newAllow, err := s.loadList(ctx, ListAllow)
+	var newDeny []netip.Prefix
+	if err == nil {
+		newDeny, err = s.loadList(ctx, ListDeny)
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.allow, s.deny, s.loaded = newAllow, newDeny, true
+	}
+
+	// Rules changed through invalidate during the reload may not be in the
+	// lists read, so they stay stale and the next request reloads again.
+	if s.loaded && s.generation == generation {
+		s.loadedAt = time.Now()
+	}
+
+	call.err = err
+	s.reload = nil
+	allow, deny, loaded = s.allow, s.deny, s.loaded
+	s.mu.Unlock()
+	close(call.done)
+
+	if !loaded {
+		return nil, nil, err
+	}
+
+	return allow, deny, nil
+}
+
+func (s *Service) loadList(ctx context.Context, list string) ([]netip.Prefix, error) {
+	values, err := s.repo.Rules(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		// Entries are validated when added, so anything else was put there
+		// by hand and is ignored.
+		prefix, err := ParsePrefix(value)
+		if err != nil {
+			continue
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+// invalidate makes the next check reload the rules. Other instances pick up
+// changes once their cache is stale.
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.generation++
+	s.mu.Unlock()
+}
+
+// ParsePrefix parses an IP or a CIDR range, treating plain IPs as ranges
+// containing only themselves.
+func ParsePrefix(value string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return prefix.Masked(), nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package organization
+
+import (
+	"strings"
+	"time"
+
+	"github.com/BurakYs/go-api-example/util/pagination"
+)
+
+type CreateBody struct {
+	Name string `json:"name" validate:"required,min=2,max=64"`
+}
+
+func (b *CreateBody) Normalize() {
+	b.Name = strings.TrimSpace(b.Name)
+}
+
+type OrganizationParams struct {
+	OrganizationID string `uri:"orgId" validate:"required,uuid"`
+}
+
+type MemberParams struct {
+	OrganizationID string `uri:"orgId"  validate:"required,uuid"`
+	UserID         string `uri:"userId" validate:"required,uuid"`
+}
+
+type InvitationParams struct {
+	OrganizationID string `uri:"orgId"        validate:"required,uuid"`
+	InvitationID   string `uri:"invitationId" validate:"required,uuid"`
+}
+
+type UpdateMemberBody struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+type InviteBody struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role"  validate:"required,oneof=owner admin member"`
+}
+
+func (b *InviteBody) Normalize() {
+	b.Email = strings.TrimSpace(strings.ToLower(b.Email))
+}
+
+type AcceptInvitationBody struct {
+	Token string `json:"token" validate:"required,hexadecimal,len=64"`
+}
+
+func (b *AcceptInvitationBody) Normalize() {
+	b.Token = strings.TrimSpace(b.Token)
+}
+
+type ListQuery struct {
+	pagination.Query
+}
+
+type OrganizationResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func NewOrganizationResponse(org *Organization, role string) OrganizationResponse {
+	return OrganizationResponse{
+		ID:        org.ID,
+		Name:      org.Name,
+		Role:      role,
+		CreatedAt: org.CreatedAt,
+	}
+}
+
+type InvitationResponse struct {
+	*Invitation
+
+	// Token is only returned when the invitation is created. There is no
+	// mail delivery yet, so the inviter passes it on to the invitee.
+	Token string `json:"token,omitempty"`
+}
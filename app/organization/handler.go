@@ -0,0 +1,208 @@
+package organization
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/app/user"
+	"github.com/BurakYs/go-api-example/middleware"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+type Handler struct {
+	svc     *Service
+	userSvc *user.Service
+}
+
+func NewHandler(svc *Service, userSvc *user.Service) *Handler {
+	return &Handler{
+		svc:     svc,
+		userSvc: userSvc,
+	}
+}
+
+// RequireRole loads the current user's membership in the :orgId
+// organization into the request context and rejects users without at least
+// the given role. Non-members get a 404 so organization IDs can't be probed.
+// It must run after RequireAuth.
+func (h *Handler) RequireRole(role string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		params, err := middleware.ValidateParams[OrganizationParams](c)
+		if err != nil {
+			return err
+		}
+
+		membership, err := h.svc.GetMembership(c, params.OrganizationID, rctx.GetUserID(c))
+		if err != nil {
+			if errors.Is(err, ErrMemberNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		if !HasRole(membership.Role, role) {
+			return ErrInsufficientRole
+		}
+
+		rctx.SetOrganization(c, membership.OrganizationID, membership.Role)
+		return c.Next()
+	}
+}
+
+func (h *Handler) Create(c fiber.Ctx) error {
+	body, err := middleware.ValidateBody[CreateBody](c)
+	if err != nil {
+		return err
+	}
+
+	org, err := h.svc.Create(c, body.Name, rctx.GetUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(NewOrganizationResponse(org, RoleOwner))
+}
+
+func (h *Handler) List(c fiber.Ctx) error {
+	query, err := middleware.ValidateQuery[ListQuery](c)
+	if err != nil {
+		return err
+	}
+
+	orgs, memberships, nextCursor, err := h.svc.ListForUser(c, rctx.GetUserID(c), query.Query)
+	if err != nil {
+		return err
+	}
+
+	data := make([]OrganizationResponse, len(orgs))
+	for i, org := range orgs {
+		data[i] = NewOrganizationResponse(org, memberships[i].Role)
+	}
+
+	pagination.SetLinkHeader(c, nextCursor)
+	return c.JSON(pagination.NewResponse(data, nextCursor))
+}
+
+func (h *Handler) Get(c fiber.Ctx) error {
+	org, err := h.svc.GetByID(c, rctx.GetOrganizationID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(NewOrganizationResponse(org, rctx.GetOrganizationRole(c)))
+}
+
+func (h *Handler) ListMembers(c fiber.Ctx) error {
+	query, err := middleware.ValidateQuery[ListQuery](c)
+	if err != nil {
+		return err
+	}
+
+	members, nextCursor, err := h.svc.ListMembers(c, rctx.GetOrganizationID(c), query.Query)
+	if err != nil {
+		return err
+	}
+
+	pagination.SetLinkHeader(c, nextCursor)
+	return c.JSON(pagination.NewResponse(members, nextCursor))
+}
+
+func (h *Handler) UpdateMember(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[MemberParams](c)
+	if err != nil {
+		return err
+	}
+
+	body, err := middleware.ValidateBody[UpdateMemberBody](c)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.UpdateMemberRole(c, rctx.GetOrganizationID(c), rctx.GetOrganizationRole(c), params.UserID, body.Role)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) RemoveMember(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[MemberParams](c)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.RemoveMember(c, rctx.GetOrganizationID(c), rctx.GetUserID(c), rctx.GetOrganizationRole(c), params.UserID)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) Invite(c fiber.Ctx) error {
+	body, err := middleware.ValidateBody[InviteBody](c)
+	if err != nil {
+		return err
+	}
+
+	invitation, token, err := h.svc.Invite(c, rctx.GetOrganizationID(c), rctx.GetUserID(c), rctx.GetOrganizationRole(c), body.Email, body.Role)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(InvitationResponse{
+		Invitation: invitation,
+		Token:      token,
+	})
+}
+
+func (h *Handler) ListInvitations(c fiber.Ctx) error {
+	query, err := middleware.ValidateQuery[ListQuery](c)
+	if err != nil {
+		return err
+	}
+
+	invitations, nextCursor, err := h.svc.ListInvitations(c, rctx.GetOrganizationID(c), query.Query)
+	if err != nil {
+		return err
+	}
+
+	pagination.SetLinkHeader(c, nextCursor)
+	return c.JSON(pagination.NewResponse(invitations, nextCursor))
+}
+
+func (h *Handler) RevokeInvitation(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[InvitationParams](c)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.RevokeInvitation(c, rctx.GetOrganizationID(c), params.InvitationID)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) AcceptInvitation(c fiber.Ctx) error {
+	body, err := middleware.ValidateBody[AcceptInvitationBody](c)
+	if err != nil {
+		return err
+	}
+
+	currentUser, err := h.userSvc.GetByID(c, rctx.GetUserID(c))
+	if err != nil {
+		return err
+	}
+
+	membership, err := h.svc.AcceptInvitation(c, body.Token, currentUser.ID, currentUser.Email)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(membership)
+}
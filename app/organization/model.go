@@ -0,0 +1,74 @@
+package organization
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/httperror"
+)
+
+type Organization struct {
+	ID        string    `json:"id"        bson:"_id"`
+	TenantID  string    `json:"-"         bson:"tenant_id"`
+	Name      string    `json:"name"      bson:"name"`
+	CreatedBy string    `json:"createdBy" bson:"created_by"`
+	CreatedAt time.Time `json:"createdAt" bson:"created_at"`
+
+	// Owners counts the owner memberships, so the last owner can be kept in
+	// a single conditional update. It is lowered before an owner is removed
+	// and raised after one is added, so it never counts more owners than
+	// there are.
+	Owners int `json:"-" bson:"owners"`
+}
+
+type Membership struct {
+	ID             string    `json:"id"             bson:"_id"`
+	TenantID       string    `json:"-"              bson:"tenant_id"`
+	OrganizationID string    `json:"organizationId" bson:"organization_id"`
+	UserID         string    `json:"userId"         bson:"user_id"`
+	Role           string    `json:"role"           bson:"role"`
+	CreatedAt      time.Time `json:"createdAt"      bson:"created_at"`
+}
+
+type Invitation struct {
+	ID             string    `json:"id"             bson:"_id"`
+	TenantID       string    `json:"-"              bson:"tenant_id"`
+	OrganizationID string    `json:"organizationId" bson:"organization_id"`
+	Email          string    `json:"email"          bson:"email"`
+	Role           string    `json:"role"           bson:"role"`
+	TokenHash      string    `json:"-"              bson:"token_hash"`
+	InvitedBy      string    `json:"invitedBy"      bson:"invited_by"`
+	ExpiresAt      time.Time `json:"expiresAt"      bson:"expires_at"`
+	CreatedAt      time.Time `json:"createdAt"      bson:"created_at"`
+}
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+var roleRanks = map[string]int{
+	RoleMember: 1,
+	RoleAdmin:  2,
+	RoleOwner:  3,
+}
+
+// HasRole reports whether role grants at least the access of required.
+// Owners can do everything admins can, and admins everything members can.
+func HasRole(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
+
+var (
+	ErrNotFound                = httperror.New(fiber.StatusNotFound, "Organization not found")
+	ErrMemberNotFound          = httperror.New(fiber.StatusNotFound, "Member not found")
+	ErrInvitationNotFound      = httperror.New(fiber.StatusNotFound, "Invitation not found")
+	ErrAlreadyMember           = httperror.New(fiber.StatusConflict, "User is already a member of this organization")
+	ErrInvalidInvitation       = httperror.New(fiber.StatusBadRequest, "Invalid or expired invitation")
+	ErrInvitationEmailMismatch = httperror.New(fiber.StatusForbidden, "This invitation was sent to a different email address")
+	ErrInsufficientRole        = httperror.New(fiber.StatusForbidden, "Your role in this organization does not allow this action")
+	ErrLastOwner               = httperror.New(fiber.StatusBadRequest, "An organization must keep at least one owner")
+)
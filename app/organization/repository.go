@@ -0,0 +1,366 @@
+package organization
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/BurakYs/go-api-example/database"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/tenant"
+)
+
+type Repository struct {
+	organizations *mongo.Collection
+	memberships   *mongo.Collection
+	invitations   *mongo.Collection
+}
+
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{
+		organizations: db.GetCollection("organizations"),
+		memberships:   db.GetCollection("memberships"),
+		invitations:   db.GetCollection("invitations"),
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, org *Organization) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	org.TenantID = tenantID
+
+	_, err = r.organizations.InsertOne(ctx, org)
+	return err
+}
+
+func (r *Repository) GetByID(ctx context.Context, id string) (*Organization, error) {
+	filter, err := tenant.Scope(ctx, bson.M{"_id": id})
+	if err != nil {
+		return nil, err
+	}
+
+	var org Organization
+
+	err = r.organizations.FindOne(ctx, filter).Decode(&org)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (r *Repository) GetByIDs(ctx context.Context, ids []string) ([]*Organization, error) {
+	filter, err := tenant.Scope(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.organizations.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := make([]*Organization, 0, len(ids))
+	err = cursor.All(ctx, &orgs)
+	if err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+func (r *Repository) CreateMembership(ctx context.Context, membership *Membership) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	membership.TenantID = tenantID
+
+	_, err = r.memberships.InsertOne(ctx, membership)
+	if err != nil && mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyMember
+	}
+
+	return err
+}
+
+func (r *Repository) GetMembership(ctx context.Context, orgID, userID string) (*Membership, error) {
+	filter, err := tenant.Scope(ctx, bson.M{"organization_id": orgID, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var membership Membership
+
+	err = r.memberships.FindOne(ctx, filter).Decode(&membership)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+func (r *Repository) ListMembershipsByUser(ctx context.Context, userID string, page pagination.Query) ([]*Membership, string, error) {
+	filter, err := tenant.Scope(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pagination.Find[Membership](ctx, r.memberships, filter, page, pagination.NewestFirst)
+}
+
+func (r *Repository) ListMembershipsByOrganization(ctx context.Context, orgID string, page pagination.Query) ([]*Membership, string, error) {
+	filter, err := tenant.Scope(ctx, bson.M{"organization_id": orgID})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pagination.Find[Membership](ctx, r.memberships, filter, page, pagination.NewestFirst)
+}
+
+// AddOwner counts a new owner of the organization.
+func (r *Repository) AddOwner(ctx context.Context, orgID string) error {
+	filter, err := tenant.Scope(ctx, bson.M{"_id": orgID})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.organizations.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"owners": 1}})
+	return err
+}
+
+// ReleaseOwner stops counting an owner of the organization, returning
+// ErrLastOwner instead if it is the only one.
+func (r *Repository) ReleaseOwner(ctx context.Context, orgID string) error {
+	filter, err := tenant.Scope(ctx, bson.M{"_id": orgID, "owners": bson.M{"$gt": 1}})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.organizations.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"owners": -1}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+// UpdateMembershipRole changes the role of a member from one role to
+// another. It returns ErrMemberNotFound if the member no longer has the
+// role it is changed from.
+func (r *Repository) UpdateMembershipRole(ctx context.Context, orgID, userID, from, to string) error {
+	filter, err := tenant.Scope(ctx, bson.M{"organization_id": orgID, "user_id": userID, "role": from})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.memberships.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"role": to}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrMemberNotFound
+	}
+
+	return nil
+}
+
+// DeleteMembership removes a member with the given role. It returns
+// ErrMemberNotFound if the member no longer has that role.
+func (r *Repository) DeleteMembership(ctx context.Context, orgID, userID, role string) error {
+	filter, err := tenant.Scope(ctx, bson.M{"organization_id": orgID, "user_id": userID, "role": role})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.memberships.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrMemberNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateInvitation(ctx context.Context, invitation *Invitation) error {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	invitation.TenantID = tenantID
+
+	_, err = r.invitations.InsertOne(ctx, invitation)
+	return err
+}
+
+func (r *Repository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*Invitation, error) {
+	filter, err := tenant.Scope(ctx, bson.M{"token_hash": tokenHash})
+	if err != nil {
+		return nil, err
+	}
+
+	var invitation Invitation
+
+	err = r.invitations.FindOne(ctx, filter).Decode(&invitation)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+func (r *Repository) ListInvitations(ctx context.Context, orgID string, page pagination.Query) ([]*Invitation, string, error) {
+	filter, err := tenant.Scope(ctx, bson.M{"organization_id": orgID})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pagination.Find[Invitation](ctx, r.invitations, filter, page, pagination.NewestFirst)
+}
+
+func (r *Repository) DeleteInvitation(ctx context.Context, orgID, id string) error {
+	filter, err := tenant.Scope(ctx, bson.M{"_id": id, "organization_id": orgID})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.invitations.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateIndexes(ctx context.Context) error {
+	_, err := r.memberships.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "organization_id", Value: 1},
+				bson.E{Key: "user_id", Value: 1},
+			),
+			Options: options.Index().SetUnique(true).SetName("tenant_organization_user_index"),
+		},
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "user_id", Value: 1},
+				bson.E{Key: "created_at", Value: -1},
+				bson.E{Key: "_id", Value: -1},
+			),
+			Options: options.Index().SetName("tenant_user_created_at_index"),
+		},
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "organization_id", Value: 1},
+				bson.E{Key: "created_at", Value: -1},
+				bson.E{Key: "_id", Value: -1},
+			),
+			Options: options.Index().SetName("tenant_organization_created_at_index"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = database.DropIndexes(ctx, r.memberships, "organization_user_index", "user_created_at_index", "organization_created_at_index")
+	if err != nil {
+		return err
+	}
+
+	_, err = r.invitations.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "token_hash", Value: 1},
+			),
+			Options: options.Index().SetUnique(true).SetName("tenant_token_hash_index"),
+		},
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "organization_id", Value: 1},
+				bson.E{Key: "created_at", Value: -1},
+				bson.E{Key: "_id", Value: -1},
+			),
+			Options: options.Index().SetName("tenant_organization_created_at_index"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "expires_at", Value: 1},
+			},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_index"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// These were replaced by the tenant-prefixed indexes above.
+	return database.DropIndexes(ctx, r.invitations, "token_hash_index", "organization_created_at_index")
+}
+
+// BackfillTenant assigns organizations, memberships and invitations created
+// before tenancy to tenantID.
+func (r *Repository) BackfillTenant(ctx context.Context, tenantID string) error {
+	filter := bson.M{"tenant_id": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"tenant_id": tenantID}}
+
+	for _, collection := range []*mongo.Collection{r.organizations, r.memberships, r.invitations} {
+		_, err := collection.UpdateMany(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BackfillOwners counts the owners of organizations created before they
+// were counted.
+func (r *Repository) BackfillOwners(ctx context.Context) error {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"role": RoleOwner}},
+		bson.M{"$group": bson.M{"_id": "$organization_id", "owners": bson.M{"$sum": 1}}},
+		bson.M{"$merge": bson.M{
+			"into": r.organizations.Name(),
+			"whenMatched": bson.A{
+				bson.M{"$set": bson.M{"owners": bson.M{"$ifNull": bson.A{"$owners", "$$new.owners"}}}},
+			},
+			"whenNotMatched": "discard",
+		}},
+	}
+
+	cursor, err := r.memberships.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	return cursor.Close(ctx)
+}
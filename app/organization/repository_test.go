@@ -0,0 +1,304 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BurakYs/go-api-example/database/dbtest"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/tenant"
+)
+
+const (
+	sharedUserID    = "user"
+	sharedTokenHash = "token-hash"
+)
+
+// newTenantRepository returns a repository holding an organization
+// "org-<tenant>" in tenants "a" and "b". The same user owns both, and both
+// have an invitation "invitation-<tenant>" with the same token hash.
+func newTenantRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	repo := NewRepository(dbtest.New(t))
+	ctx := context.Background()
+
+	err := repo.CreateIndexes(ctx)
+	if err != nil {
+		t.Fatalf("CreateIndexes: %v", err)
+	}
+
+	for _, tenantID := range []string{"a", "b"} {
+		ctx := tenant.NewContext(ctx, tenantID)
+		orgID := "org-" + tenantID
+
+		// Two owners, so only the tenant keeps ReleaseOwner in tenant "a"
+		// from lowering the count of "b".
+		err = repo.Create(ctx, &Organization{ID: orgID, Name: "Org " + tenantID, Owners: 2, CreatedAt: time.Now()})
+		if err != nil {
+			t.Fatalf("Create in tenant %q: %v", tenantID, err)
+		}
+
+		err = repo.CreateMembership(ctx, &Membership{
+			ID:             "membership-" + tenantID,
+			OrganizationID: orgID,
+			UserID:         sharedUserID,
+			Role:           RoleOwner,
+			CreatedAt:      time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("CreateMembership in tenant %q: %v", tenantID, err)
+		}
+
+		err = repo.CreateInvitation(ctx, &Invitation{
+			ID:             "invitation-" + tenantID,
+			OrganizationID: orgID,
+			Email:          "invitee@example.com",
+			Role:           RoleMember,
+			TokenHash:      sharedTokenHash,
+			ExpiresAt:      time.Now().Add(time.Hour),
+			CreatedAt:      time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("CreateInvitation in tenant %q: %v", tenantID, err)
+		}
+	}
+
+	return repo
+}
+
+func TestRepositoryTenantIsolation(t *testing.T) {
+	repo := newTenantRepository(t)
+
+	tests := []struct {
+		name    string
+		tenant  string
+		get     func(ctx context.Context) (string, string, error)
+		wantID  string
+		wantErr error
+	}{
+		{
+			name:   "organization in own tenant",
+			tenant: "a",
+			get: func(ctx context.Context) (string, string, error) {
+				org, err := repo.GetByID(ctx, "org-a")
+				if err != nil {
+					return "", "", err
+				}
+				return org.ID, org.TenantID, nil
+			},
+			wantID: "org-a",
+		},
+		{
+			name:   "organization of other tenant",
+			tenant: "a",
+			get: func(ctx context.Context) (string, string, error) {
+				org, err := repo.GetByID(ctx, "org-b")
+				if err != nil {
+					return "", "", err
+				}
+				return org.ID, org.TenantID, nil
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name:   "membership of other tenant",
+			tenant: "a",
+			get: func(ctx context.Context) (string, string, error) {
+				membership, err := repo.GetMembership(ctx, "org-b", sharedUserID)
+				if err != nil {
+					return "", "", err
+				}
+				return membership.ID, membership.TenantID, nil
+			},
+			wantErr: ErrMemberNotFound,
+		},
+		{
+			name:   "same token hash in own tenant",
+			tenant: "b",
+			get: func(ctx context.Context) (string, string, error) {
+				invitation, err := repo.GetInvitationByTokenHash(ctx, sharedTokenHash)
+				if err != nil {
+					return "", "", err
+				}
+				return invitation.ID, invitation.TenantID, nil
+			},
+			wantID: "invitation-b",
+		},
+		{
+			name:   "token hash in unknown tenant",
+			tenant: "c",
+			get: func(ctx context.Context) (string, string, error) {
+				invitation, err := repo.GetInvitationByTokenHash(ctx, sharedTokenHash)
+				if err != nil {
+					return "", "", err
+				}
+				return invitation.ID, invitation.TenantID, nil
+			},
+			wantErr: ErrInvitationNotFound,
+		},
+		{
+			name: "no tenant",
+			get: func(ctx context.Context) (string, string, error) {
+				org, err := repo.GetByID(ctx, "org-a")
+				if err != nil {
+					return "", "", err
+				}
+				return org.ID, org.TenantID, nil
+			},
+			wantErr: tenant.ErrMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = tenant.NewContext(ctx, tt.tenant)
+			}
+
+			id, tenantID, err := tt.get(ctx)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if id != tt.wantID || tenantID != tt.tenant {
+				t.Fatalf("got %q of tenant %q, want %q of tenant %q", id, tenantID, tt.wantID, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestRepositoryListTenantIsolation(t *testing.T) {
+	repo := newTenantRepository(t)
+
+	tests := []struct {
+		name    string
+		tenant  string
+		wantIDs []string
+		wantErr error
+	}{
+		{name: "tenant a", tenant: "a", wantIDs: []string{"membership-a"}},
+		{name: "tenant b", tenant: "b", wantIDs: []string{"membership-b"}},
+		{name: "unknown tenant", tenant: "c"},
+		{name: "no tenant", wantErr: tenant.ErrMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = tenant.NewContext(ctx, tt.tenant)
+			}
+
+			memberships, _, err := repo.ListMembershipsByUser(ctx, sharedUserID, pagination.Query{})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(memberships) != len(tt.wantIDs) {
+				t.Fatalf("got %d memberships, want %d", len(memberships), len(tt.wantIDs))
+			}
+
+			for i, membership := range memberships {
+				if membership.ID != tt.wantIDs[i] {
+					t.Fatalf("got membership %q, want %q", membership.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+// TestRepositoryModifyOtherTenant checks that writes naming records of
+// tenant "b" don't reach them from tenant "a".
+func TestRepositoryModifyOtherTenant(t *testing.T) {
+	repo := newTenantRepository(t)
+	ctx := tenant.NewContext(context.Background(), "a")
+
+	tests := []struct {
+		name    string
+		modify  func(ctx context.Context) error
+		wantErr error
+	}{
+		{
+			name: "update membership role",
+			modify: func(ctx context.Context) error {
+				return repo.UpdateMembershipRole(ctx, "org-b", sharedUserID, RoleOwner, RoleMember)
+			},
+			wantErr: ErrMemberNotFound,
+		},
+		{
+			name:    "delete membership",
+			modify:  func(ctx context.Context) error { return repo.DeleteMembership(ctx, "org-b", sharedUserID, RoleOwner) },
+			wantErr: ErrMemberNotFound,
+		},
+		{
+			name:    "release owner",
+			modify:  func(ctx context.Context) error { return repo.ReleaseOwner(ctx, "org-b") },
+			wantErr: ErrLastOwner,
+		},
+		{
+			name:    "delete invitation",
+			modify:  func(ctx context.Context) error { return repo.DeleteInvitation(ctx, "org-b", "invitation-b") },
+			wantErr: ErrInvitationNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.modify(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Tenant "b" still sees its records as they were.
+	ctx = tenant.NewContext(context.Background(), "b")
+
+	org, err := repo.GetByID(ctx, "org-b")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if org.Owners != 2 {
+		t.Fatalf("got %d owners, want 2", org.Owners)
+	}
+
+	membership, err := repo.GetMembership(ctx, "org-b", sharedUserID)
+	if err != nil {
+		t.Fatalf("GetMembership: %v", err)
+	}
+	if membership.Role != RoleOwner {
+		t.Fatalf("got role %q, want %q", membership.Role, RoleOwner)
+	}
+
+	_, err = repo.GetInvitationByTokenHash(ctx, sharedTokenHash)
+	if err != nil {
+		t.Fatalf("GetInvitationByTokenHash: %v", err)
+	}
+}
+
+func TestRepositoryCreateWithoutTenant(t *testing.T) {
+	repo := NewRepository(dbtest.New(t))
+
+	err := repo.Create(context.Background(), &Organization{ID: "org"})
+	if !errors.Is(err, tenant.ErrMissing) {
+		t.Fatalf("got error %v, want %v", err, tenant.ErrMissing)
+	}
+}
@@ -0,0 +1,303 @@
+package organization
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/BurakYs/go-api-example/util/pagination"
+)
+
+type Service struct {
+	repo                 *Repository
+	invitationExpiration time.Duration
+}
+
+func NewService(repo *Repository, invitationExpiration time.Duration) *Service {
+	return &Service{
+		repo:                 repo,
+		invitationExpiration: invitationExpiration,
+	}
+}
+
+// Create creates an organization with userID as its first owner.
+func (s *Service) Create(ctx context.Context, name, userID string) (*Organization, error) {
+	orgID, err := s.generateID()
+	if err != nil {
+		return nil, err
+	}
+
+	org := &Organization{
+		ID:        orgID,
+		Name:      name,
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+	}
+
+	err = s.repo.Create(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.addMember(ctx, org.ID, userID, RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+func (s *Service) GetByID(ctx context.Context, id string) (*Organization, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListForUser returns a page of the organizations the user belongs to along
+// with the user's membership in each of them.
+func (s *Service) ListForUser(ctx context.Context, userID string, page pagination.Query) ([]*Organization, []*Membership, string, error) {
+	memberships, nextCursor, err := s.repo.ListMembershipsByUser(ctx, userID, page)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	ids := make([]string, len(memberships))
+	for i, membership := range memberships {
+		ids[i] = membership.OrganizationID
+	}
+
+	found, err := s.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	byID := make(map[string]*Organization, len(found))
+	for _, org := range found {
+		byID[org.ID] = org
+	}
+
+	orgs := make([]*Organization, 0, len(memberships))
+	kept := make([]*Membership, 0, len(memberships))
+	for _, membership := range memberships {
+		org, ok := byID[membership.OrganizationID]
+		if !ok {
+			continue
+		}
+
+		orgs = append(orgs, org)
+		kept = append(kept, membership)
+	}
+
+	return orgs, kept, nextCursor, nil
+}
+
+func (s *Service) GetMembership(ctx context.Context, orgID, userID string) (*Membership, error) {
+	return s.repo.GetMembership(ctx, orgID, userID)
+}
+
+func (s *Service) ListMembers(ctx context.Context, orgID string, page pagination.Query) ([]*Membership, string, error) {
+	return s.repo.ListMembershipsByOrganization(ctx, orgID, page)
+}
+
+// UpdateMemberRole changes a member's role on behalf of a member with
+// actorRole. Only owners may grant the owner role or change an owner's role.
+func (s *Service) UpdateMemberRole(ctx context.Context, orgID, actorRole, userID, role string) error {
+	membership, err := s.repo.GetMembership(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !s.canManage(actorRole, membership.Role) || !s.canManage(actorRole, role) {
+		return ErrInsufficientRole
+	}
+
+	switch {
+	case membership.Role == role:
+		return nil
+	case membership.Role == RoleOwner:
+		return s.releaseOwner(ctx, orgID, func() error {
+			return s.repo.UpdateMembershipRole(ctx, orgID, userID, membership.Role, role)
+		})
+	case role == RoleOwner:
+		err = s.repo.UpdateMembershipRole(ctx, orgID, userID, membership.Role, role)
+		if err != nil {
+			return err
+		}
+
+		return s.repo.AddOwner(ctx, orgID)
+	default:
+		return s.repo.UpdateMembershipRole(ctx, orgID, userID, membership.Role, role)
+	}
+}
+
+// RemoveMember removes userID from the organization on behalf of actorID.
+// Members may always remove themselves, except for the last owner.
+func (s *Service) RemoveMember(ctx context.Context, orgID, actorID, actorRole, userID string) error {
+	membership, err := s.repo.GetMembership(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if actorID != userID && !s.canManage(actorRole, membership.Role) {
+		return ErrInsufficientRole
+	}
+
+	if membership.Role == RoleOwner {
+		return s.releaseOwner(ctx, orgID, func() error {
+			return s.repo.DeleteMembership(ctx, orgID, userID, membership.Role)
+		})
+	}
+
+	return s.repo.DeleteMembership(ctx, orgID, userID, membership.Role)
+}
+
+// Invite creates an invitation for email and returns it along with the token
+// the invitee needs to accept it. Only the token's hash is stored.
+func (s *Service) Invite(ctx context.Context, orgID, actorID, actorRole, email, role string) (*Invitation, string, error) {
+	if !s.canManage(actorRole, role) {
+		return nil, "", ErrInsufficientRole
+	}
+
+	invitationID, err := s.generateID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	bytes := make([]byte, 32)
+	_, _ = rand.Read(bytes)
+	token := hex.EncodeToString(bytes)
+
+	now := time.Now()
+	invitation := &Invitation{
+		ID:             invitationID,
+		OrganizationID: orgID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      hashToken(token),
+		InvitedBy:      actorID,
+		ExpiresAt:      now.Add(s.invitationExpiration),
+		CreatedAt:      now,
+	}
+
+	err = s.repo.CreateInvitation(ctx, invitation)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return invitation, token, nil
+}
+
+func (s *Service) ListInvitations(ctx context.Context, orgID string, page pagination.Query) ([]*Invitation, string, error) {
+	return s.repo.ListInvitations(ctx, orgID, page)
+}
+
+func (s *Service) RevokeInvitation(ctx context.Context, orgID, invitationID string) error {
+	return s.repo.DeleteInvitation(ctx, orgID, invitationID)
+}
+
+// AcceptInvitation adds the user to the invitation's organization. The
+// invitation is single use and only valid for the email it was sent to.
+func (s *Service) AcceptInvitation(ctx context.Context, token, userID, email string) (*Membership, error) {
+	invitation, err := s.repo.GetInvitationByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, ErrInvitationNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvalidInvitation
+	}
+
+	if invitation.Email != email {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	membership, err := s.addMember(ctx, invitation.OrganizationID, userID, invitation.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.DeleteInvitation(ctx, invitation.OrganizationID, invitation.ID)
+	if err != nil && !errors.Is(err, ErrInvitationNotFound) {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+func (s *Service) addMember(ctx context.Context, orgID, userID, role string) (*Membership, error) {
+	membershipID, err := s.generateID()
+	if err != nil {
+		return nil, err
+	}
+
+	membership := &Membership{
+		ID:             membershipID,
+		OrganizationID: orgID,
+		UserID:         userID,
+		Role:           role,
+		CreatedAt:      time.Now(),
+	}
+
+	err = s.repo.CreateMembership(ctx, membership)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == RoleOwner {
+		err = s.repo.AddOwner(ctx, orgID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return membership, nil
+}
+
+// canManage reports whether a member with actorRole may act on members or
+// invitations with the given role: owners manage everyone, admins manage
+// everyone but owners.
+func (s *Service) canManage(actorRole, role string) bool {
+	if actorRole == RoleOwner {
+		return true
+	}
+
+	return actorRole == RoleAdmin && role != RoleOwner
+}
+
+// releaseOwner runs demote, which removes an owner, once the organization
+// is known to keep another one. Two owners demoting each other at once can't
+// both pass, since the owner count is checked and lowered in one update.
+func (s *Service) releaseOwner(ctx context.Context, orgID string, demote func() error) error {
+	err := s.repo.ReleaseOwner(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	err = demote()
+	if err != nil {
+		_ = s.repo.AddOwner(ctx, orgID)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) generateID() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+
+	return id.String(), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,16 @@
+package ratelimit
+
+type NameParams struct {
+	Name string `uri:"name" validate:"required,max=64"`
+}
+
+// PolicyBody needs no limits for disabled policies, since they lift the
+// limit anyway.
+type PolicyBody struct {
+	Max      int  `json:"max"      validate:"required_unless=Disabled true,omitempty,min=1,max=1000000"`
+	Burst    int  `json:"burst"    validate:"omitempty,min=1,max=1000000"`
+	Disabled bool `json:"disabled"`
+
+	// Window is in seconds.
+	Window int `json:"window" validate:"required_unless=Disabled true,omitempty,min=1,max=2678400"`
+}
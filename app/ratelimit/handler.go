@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/middleware"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+type Handler struct {
+	svc         *Service
+	rateLimiter *middleware.RateLimiter
+}
+
+func NewHandler(svc *Service, rateLimiter *middleware.RateLimiter) *Handler {
+	return &Handler{
+		svc:         svc,
+		rateLimiter: rateLimiter,
+	}
+}
+
+func (h *Handler) List(c fiber.Ctx) error {
+	policies, err := h.svc.List(c, h.rateLimiter.Policies())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(pagination.NewResponse(policies, ""))
+}
+
+func (h *Handler) Save(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[NameParams](c)
+	if err != nil {
+		return err
+	}
+
+	// A policy no route refers to would have no effect, which is most
+	// likely a typo in its name.
+	if _, ok := h.rateLimiter.Policies()[params.Name]; !ok {
+		return ErrUnknownPolicy
+	}
+
+	body, err := middleware.ValidateBody[PolicyBody](c)
+	if err != nil {
+		return err
+	}
+
+	policy := &Policy{
+		Name:     params.Name,
+		Max:      body.Max,
+		Burst:    body.Burst,
+		Disabled: body.Disabled,
+		Window:   body.Window,
+	}
+
+	err = h.svc.Save(c, policy, rctx.GetUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(policy)
+}
+
+func (h *Handler) Delete(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[NameParams](c)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.Delete(c, params.Name)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/httperror"
+)
+
+// Policy is a named rate limit routes can refer to, replacing the limit they
+// were configured with at startup. Disabled policies lift the limit.
+type Policy struct {
+	Name     string `json:"name"`
+	Max      int    `json:"max"`
+	Burst    int    `json:"burst,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+
+	// Window is in seconds.
+	Window int `json:"window"`
+
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// PolicyInfo describes a policy name: the limit the routes referring to it
+// were configured with, and the saved policy replacing it, if any. Saved
+// policies no route refers to anymore have no configured limit.
+type PolicyInfo struct {
+	Name       string        `json:"name"`
+	Configured *PolicyLimits `json:"configured,omitempty"`
+	Saved      *Policy       `json:"saved,omitempty"`
+}
+
+type PolicyLimits struct {
+	Max   int `json:"max"`
+	Burst int `json:"burst,omitempty"`
+
+	// Window is in seconds.
+	Window int `json:"window"`
+}
+
+var (
+	ErrNotFound      = httperror.New(fiber.StatusNotFound, "Rate limit policy not found")
+	ErrUnknownPolicy = httperror.New(fiber.StatusNotFound, "No route uses this rate limit policy")
+)
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/BurakYs/go-api-example/database"
+)
+
+const (
+	policiesKey = "rate_limit_policies"
+
+	// policiesChannel is notified with the name of every changed policy.
+	policiesChannel = "rate_limit_policies"
+)
+
+type Repository struct {
+	redis *database.Redis
+}
+
+func NewRepository(redis *database.Redis) *Repository {
+	return &Repository{
+		redis: redis,
+	}
+}
+
+func (r *Repository) List(ctx context.Context) ([]*Policy, error) {
+	values, err := r.redis.Client().HGetAll(ctx, policiesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]*Policy, 0, len(values))
+	for _, value := range values {
+		var policy Policy
+		err = json.Unmarshal([]byte(value), &policy)
+		if err != nil {
+			return nil, err
+		}
+
+		policies = append(policies, &policy)
+	}
+
+	return policies, nil
+}
+
+func (r *Repository) Save(ctx context.Context, policy *Policy) error {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	err = r.redis.Client().HSet(ctx, policiesKey, policy.Name, data).Err()
+	if err != nil {
+		return err
+	}
+
+	return r.redis.Client().Publish(ctx, policiesChannel, policy.Name).Err()
+}
+
+func (r *Repository) Delete(ctx context.Context, name string) error {
+	deleted, err := r.redis.Client().HDel(ctx, policiesKey, name).Result()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return r.redis.Client().Publish(ctx, policiesChannel, name).Err()
+}
+
+func (r *Repository) Subscribe(ctx context.Context) *redis.PubSub {
+	return r.redis.Client().Subscribe(ctx, policiesChannel)
+}
@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/BurakYs/go-api-example/middleware"
+)
+
+// Service keeps every policy in memory so rate limits can look them up on
+// each request, and reloads them whenever any instance changes one.
+type Service struct {
+	repo   *Repository
+	logger *zap.Logger
+
+	mu       sync.RWMutex
+	policies map[string]*Policy
+}
+
+func NewService(repo *Repository, logger *zap.Logger) *Service {
+	return &Service{
+		repo:     repo,
+		logger:   logger,
+		policies: make(map[string]*Policy),
+	}
+}
+
+func (s *Service) Load(ctx context.Context) error {
+	policies, err := s.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]*Policy, len(policies))
+	for _, policy := range policies {
+		byName[policy.Name] = policy
+	}
+
+	s.mu.Lock()
+	s.policies = byName
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the policies when they change until ctx is done. They are
+// also reloaded whenever the subscription is established again, since
+// notifications sent while disconnected are lost.
+func (s *Service) Watch(ctx context.Context) {
+	pubsub := s.repo.Subscribe(ctx)
+	defer pubsub.Close()
+
+	messages := pubsub.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			if subscription, ok := message.(*redis.Subscription); ok && subscription.Kind != "subscribe" {
+				continue
+			}
+
+			err := s.Load(ctx)
+			if err != nil {
+				s.logger.Error("Failed to reload rate limit policies", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Policy implements middleware.PolicySource.
+func (s *Service) Policy(name string) (middleware.Limit, bool) {
+	s.mu.RLock()
+	policy, ok := s.policies[name]
+	s.mu.RUnlock()
+
+	if !ok {
+		return middleware.Limit{}, false
+	}
+
+	if policy.Disabled {
+		return middleware.Limit{}, true
+	}
+
+	return middleware.Limit{
+		Max:    policy.Max,
+		Window: time.Duration(policy.Window) * time.Second,
+		Burst:  policy.Burst,
+	}, true
+}
+
+// List returns every policy name routes refer to, as given by configured,
+// and every saved policy.
+func (s *Service) List(ctx context.Context, configured map[string]middleware.Limit) ([]*PolicyInfo, error) {
+	policies, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*PolicyInfo, len(configured))
+	for name, limit := range configured {
+		byName[name] = &PolicyInfo{
+			Name: name,
+			Configured: &PolicyLimits{
+				Max:    limit.Max,
+				Burst:  limit.Burst,
+				Window: int(limit.Window / time.Second),
+			},
+		}
+	}
+
+	for _, policy := range policies {
+		info, ok := byName[policy.Name]
+		if !ok {
+			info = &PolicyInfo{Name: policy.Name}
+			byName[policy.Name] = info
+		}
+
+		info.Saved = policy
+	}
+
+	infos := slices.Collect(maps.Values(byName))
+	slices.SortFunc(infos, func(a, b *PolicyInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return infos, nil
+}
+
+// Save creates or replaces the policy. It applies to this instance right
+// away and to the others once they are notified.
+func (s *Service) Save(ctx context.Context, policy *Policy, updatedBy string) error {
+	policy.UpdatedBy = updatedBy
+	policy.UpdatedAt = time.Now()
+
+	err := s.repo.Save(ctx, policy)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.policies[policy.Name] = policy
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Delete removes the policy, restoring the limits routes were configured
+// with.
+func (s *Service) Delete(ctx context.Context, name string) error {
+	err := s.repo.Delete(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.policies, name)
+	s.mu.Unlock()
+
+	return nil
+}
@@ -6,4 +6,23 @@ import (
 	"github.com/BurakYs/go-api-example/httperror"
 )
 
+// Session only identifies the user. Their roles and plan are read from the
+// user on each request, so they are not stored here.
+type Session struct {
+	UserID   string `json:"userId"`
+	TenantID string `json:"tenantId,omitempty"`
+
+	// IP is the client IP the session was created from.
+	IP string `json:"ip,omitempty"`
+
+	// Restricted sessions may only reach routes that explicitly allow them,
+	// e.g. to change an expired password.
+	Restricted bool `json:"restricted,omitempty"`
+
+	// ImpersonatorID is set when an admin acts as UserID. The admin's own
+	// session is kept so it can be restored once impersonation stops.
+	ImpersonatorID        string `json:"impersonatorId,omitempty"`
+	ImpersonatorSessionID string `json:"impersonatorSessionId,omitempty"`
+}
+
 var ErrNotFound = httperror.New(fiber.StatusNotFound, "Session not found")
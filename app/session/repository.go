@@ -4,7 +4,9 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -22,40 +24,56 @@ func NewRepository(redis *database.Redis) *Repository {
 	}
 }
 
-func (r *Repository) Create(ctx context.Context, userID string, expiration time.Duration) (string, error) {
+func (r *Repository) Create(ctx context.Context, session *Session, expiration time.Duration) (string, error) {
 	sessionID := r.generateSessionID()
 
-	err := r.redis.Set(ctx, r.sessionKey(sessionID), userID, expiration)
+	data, err := json.Marshal(session)
 	if err != nil {
 		return "", err
 	}
 
-	err = r.redis.Client().SAdd(ctx, r.userSetKey(userID), sessionID).Err()
+	err = r.redis.Set(ctx, r.sessionKey(sessionID), data, expiration)
 	if err != nil {
 		return "", err
 	}
 
+	for _, setKey := range r.userSetKeys(session) {
+		err = r.redis.Client().SAdd(ctx, setKey, sessionID).Err()
+		if err != nil {
+			return "", err
+		}
+	}
+
 	return sessionID, nil
 }
 
-func (r *Repository) Get(ctx context.Context, sessionID string) (string, error) {
+func (r *Repository) Get(ctx context.Context, sessionID string) (*Session, error) {
 	key := r.sessionKey(sessionID)
 
 	val, err := r.redis.Get(ctx, key)
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			return "", ErrNotFound
+			return nil, ErrNotFound
 		}
-		return "", err
+		return nil, err
 	}
 
-	return val, nil
+	return r.decodeSession(val)
+}
+
+func (r *Repository) Exists(ctx context.Context, sessionID string) (bool, error) {
+	n, err := r.redis.Client().Exists(ctx, r.sessionKey(sessionID)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
 }
 
 func (r *Repository) Delete(ctx context.Context, sessionID string) error {
 	key := r.sessionKey(sessionID)
 
-	userID, err := r.redis.Get(ctx, key)
+	val, err := r.redis.Get(ctx, key)
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil
@@ -63,16 +81,23 @@ func (r *Repository) Delete(ctx context.Context, sessionID string) error {
 		return err
 	}
 
-	err = r.redis.Del(ctx, key)
+	session, err := r.decodeSession(val)
 	if err != nil {
 		return err
 	}
 
-	err = r.redis.Client().SRem(ctx, r.userSetKey(userID), sessionID).Err()
+	err = r.redis.Del(ctx, key)
 	if err != nil {
 		return err
 	}
 
+	for _, setKey := range r.userSetKeys(session) {
+		err = r.redis.Client().SRem(ctx, setKey, sessionID).Err()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -98,10 +123,38 @@ func (r *Repository) DeleteAllForUser(ctx context.Context, userID string) error
 	return r.redis.Del(ctx, keys...)
 }
 
+// decodeSession also accepts the plain user ID values stored by older
+// versions so existing sessions stay valid after an upgrade.
+func (r *Repository) decodeSession(val string) (*Session, error) {
+	if !strings.HasPrefix(val, "{") {
+		return &Session{UserID: val}, nil
+	}
+
+	var session Session
+	err := json.Unmarshal([]byte(val), &session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
 func (r *Repository) sessionKey(id string) string { return "session:" + id }
 
 func (r *Repository) userSetKey(userID string) string { return "user_sessions:" + userID }
 
+// userSetKeys returns the sets session is indexed in. Impersonation sessions
+// are also indexed under the admin, so revoking the admin's sessions ends
+// them too.
+func (r *Repository) userSetKeys(session *Session) []string {
+	keys := []string{r.userSetKey(session.UserID)}
+	if session.ImpersonatorID != "" {
+		keys = append(keys, r.userSetKey(session.ImpersonatorID))
+	}
+
+	return keys
+}
+
 func (r *Repository) generateSessionID() string {
 	bytes := make([]byte, 32)
 	_, _ = rand.Read(bytes)
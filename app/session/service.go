@@ -3,26 +3,61 @@ package session
 import (
 	"context"
 	"time"
+
+	"github.com/BurakYs/go-api-example/util/rctx"
+	"github.com/BurakYs/go-api-example/util/tenant"
 )
 
 type Service struct {
-	repo       *Repository
-	expiration time.Duration
+	repo                    *Repository
+	expiration              time.Duration
+	impersonationExpiration time.Duration
+	defaultTenant           string
 }
 
-func NewService(repo *Repository, expiration time.Duration) *Service {
+func NewService(repo *Repository, expiration, impersonationExpiration time.Duration, defaultTenant string) *Service {
 	return &Service{
-		repo:       repo,
-		expiration: expiration,
+		repo:                    repo,
+		expiration:              expiration,
+		impersonationExpiration: impersonationExpiration,
+		defaultTenant:           defaultTenant,
 	}
 }
 
 func (s *Service) Create(ctx context.Context, userID string) (string, error) {
-	return s.repo.Create(ctx, userID, s.expiration)
+	return s.create(ctx, &Session{UserID: userID}, s.expiration)
+}
+
+func (s *Service) CreateRestricted(ctx context.Context, userID string) (string, error) {
+	return s.create(ctx, &Session{UserID: userID, Restricted: true}, s.expiration)
 }
 
-func (s *Service) GetUserID(ctx context.Context, sessionID string) (string, error) {
-	return s.repo.Get(ctx, sessionID)
+// CreateImpersonation creates a session acting as userID on behalf of the
+// admin owning impersonatorSessionID.
+func (s *Service) CreateImpersonation(ctx context.Context, userID, impersonatorID, impersonatorSessionID string) (string, error) {
+	return s.create(ctx, &Session{
+		UserID:                userID,
+		ImpersonatorID:        impersonatorID,
+		ImpersonatorSessionID: impersonatorSessionID,
+	}, s.impersonationExpiration)
+}
+
+func (s *Service) Get(ctx context.Context, sessionID string) (*Session, error) {
+	session, err := s.repo.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions created before tenancy belong to the default tenant.
+	if session.TenantID == "" {
+		session.TenantID = s.defaultTenant
+	}
+
+	return session, nil
+}
+
+func (s *Service) Exists(ctx context.Context, sessionID string) (bool, error) {
+	return s.repo.Exists(ctx, sessionID)
 }
 
 func (s *Service) Delete(ctx context.Context, sessionID string) error {
@@ -32,3 +67,16 @@ func (s *Service) Delete(ctx context.Context, sessionID string) error {
 func (s *Service) RevokeAllForUser(ctx context.Context, userID string) error {
 	return s.repo.DeleteAllForUser(ctx, userID)
 }
+
+// create binds the session to the tenant of the request creating it and
+// records the client IP it came from.
+func (s *Service) create(ctx context.Context, session *Session, expiration time.Duration) (string, error) {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	session.TenantID = tenantID
+	session.IP, _ = ctx.Value(rctx.ClientIPKey).(string)
+	return s.repo.Create(ctx, session, expiration)
+}
@@ -0,0 +1,241 @@
+package user
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/app/audit"
+	"github.com/BurakYs/go-api-example/app/session"
+	"github.com/BurakYs/go-api-example/config"
+	"github.com/BurakYs/go-api-example/middleware"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/rbac"
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+type AdminHandler struct {
+	svc              *Service
+	sessionSvc       *session.Service
+	auditSvc         *audit.Service
+	cookieCfg        *config.CookieConfig
+	impersonationCfg *config.ImpersonationConfig
+	rateLimitCfg     *config.RateLimitConfig
+	rbac             *rbac.RBAC
+}
+
+func NewAdminHandler(svc *Service, sessionSvc *session.Service, auditSvc *audit.Service, cookieCfg *config.CookieConfig, impersonationCfg *config.ImpersonationConfig, rateLimitCfg *config.RateLimitConfig, rbac *rbac.RBAC) *AdminHandler {
+	return &AdminHandler{
+		svc:              svc,
+		sessionSvc:       sessionSvc,
+		auditSvc:         auditSvc,
+		cookieCfg:        cookieCfg,
+		impersonationCfg: impersonationCfg,
+		rateLimitCfg:     rateLimitCfg,
+		rbac:             rbac,
+	}
+}
+
+func (h *AdminHandler) List(c fiber.Ctx) error {
+	query, err := middleware.ValidateQuery[ListUsersQuery](c)
+	if err != nil {
+		return err
+	}
+
+	users, nextCursor, err := h.svc.List(c, query.Filter(), pagination.NewestFirst, query.Query)
+	if err != nil {
+		return err
+	}
+
+	return h.sendUsers(c, users, nextCursor)
+}
+
+func (h *AdminHandler) Search(c fiber.Ctx) error {
+	query, err := middleware.ValidateQuery[SearchUsersQuery](c)
+	if err != nil {
+		return err
+	}
+
+	users, nextCursor, err := h.svc.List(c, query.Filter(), query.SortOrder(), query.Query)
+	if err != nil {
+		return err
+	}
+
+	return h.sendUsers(c, users, nextCursor)
+}
+
+func (h *AdminHandler) Get(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[UserIDParams](c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.svc.GetByID(c, params.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(NewAdminUserResponse(user))
+}
+
+func (h *AdminHandler) Disable(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[UserIDParams](c)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.SetDisabled(c, params.ID, true)
+	if err != nil {
+		return err
+	}
+
+	err = h.sessionSvc.RevokeAllForUser(c, params.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AdminHandler) Enable(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[UserIDParams](c)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.SetDisabled(c, params.ID, false)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SetPlan moves the user to another plan. Sessions pick up the new plan's
+// limits on their next request.
+func (h *AdminHandler) SetPlan(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[UserIDParams](c)
+	if err != nil {
+		return err
+	}
+
+	body, err := middleware.ValidateBody[SetPlanBody](c)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := h.rateLimitCfg.Plans[body.Plan]; !ok {
+		return middleware.NewValidationError(middleware.ValidationFailure{
+			Location: "body",
+			Field:    "plan",
+			Message:  "Unknown plan",
+		})
+	}
+
+	err = h.svc.SetPlan(c, params.ID, body.Plan)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AdminHandler) Logout(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[UserIDParams](c)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.svc.GetByID(c, params.ID)
+	if err != nil {
+		return err
+	}
+
+	err = h.sessionSvc.RevokeAllForUser(c, params.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ResetPassword issues a password reset token for the user. There is no mail
+// delivery yet, so the token is returned for support staff to pass on.
+func (h *AdminHandler) ResetPassword(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[UserIDParams](c)
+	if err != nil {
+		return err
+	}
+
+	token, expiresAt, err := h.svc.CreatePasswordResetToken(c, params.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(PasswordResetResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// Impersonate switches the admin's session cookie to a session acting as the
+// user. The admin's own session is kept and restored when impersonation stops.
+func (h *AdminHandler) Impersonate(c fiber.Ctx) error {
+	params, err := middleware.ValidateParams[UserIDParams](c)
+	if err != nil {
+		return err
+	}
+
+	adminID := rctx.GetUserID(c)
+	if params.ID == adminID {
+		return ErrSelfImpersonation
+	}
+
+	user, err := h.svc.GetByID(c, params.ID)
+	if err != nil {
+		return err
+	}
+
+	if user.Disabled {
+		return ErrAccountDisabled
+	}
+
+	// Impersonating a user grants their permissions, so admins may only
+	// impersonate users who have none beyond their own.
+	adminPermissions := rctx.GetPermissions(c)
+	for _, permission := range h.rbac.Permissions(user.Roles) {
+		if !rbac.HasPermission(adminPermissions, permission) {
+			return ErrImpersonationForbidden
+		}
+	}
+
+	err = h.auditSvc.Record(c, &audit.Entry{
+		Action:   audit.ActionImpersonationStart,
+		ActorID:  adminID,
+		TargetID: user.ID,
+		IP:       rctx.GetClientIP(c),
+	})
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := h.sessionSvc.CreateImpersonation(c, user.ID, adminID, rctx.GetSessionID(c))
+	if err != nil {
+		return err
+	}
+
+	setSessionCookie(c, h.cookieCfg, sessionID, h.impersonationCfg.Expiration)
+
+	response := NewAuthResponse(user)
+	response.Impersonating = true
+	response.ImpersonatorID = adminID
+	return c.JSON(response)
+}
+
+func (h *AdminHandler) sendUsers(c fiber.Ctx, users []*User, nextCursor string) error {
+	data := make([]AdminUserResponse, len(users))
+	for i, user := range users {
+		data[i] = NewAdminUserResponse(user)
+	}
+
+	pagination.SetLinkHeader(c, nextCursor)
+	return c.JSON(pagination.NewResponse(data, nextCursor))
+}
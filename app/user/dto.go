@@ -3,42 +3,202 @@ package user
 import (
 	"strings"
 	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/BurakYs/go-api-example/util/displayname"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/password"
 )
 
 type RegistrationBody struct {
-	Name     string `json:"name"     validate:"required,min=2,max=24,alpha_space"`
+	Name     string `json:"name"     validate:"required,min=2,max=24,display_name,single_script"`
 	Email    string `json:"email"    validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8,max=64"`
+	Password string `json:"password" validate:"required,password,min=8,max_bytes=128"`
+
+	// Invite is an invite code or signed invite link token. It is only
+	// required while registration is invite only.
+	Invite string `json:"invite" validate:"omitempty,max=256"`
 }
 
 func (b *RegistrationBody) Normalize() {
-	b.Name = strings.TrimSpace(b.Name)
+	b.Name = displayname.Normalize(b.Name)
+	b.Invite = strings.TrimSpace(b.Invite)
 	b.Email = strings.TrimSpace(strings.ToLower(b.Email))
-	b.Password = strings.TrimSpace(b.Password)
+	b.Password = password.Normalize(b.Password)
 }
 
 type LoginBody struct {
 	Email    string `json:"email"    validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8,max=64"`
+	Password string `json:"password" validate:"required,max_bytes=256"`
 }
 
 func (b *LoginBody) Normalize() {
 	b.Email = strings.TrimSpace(strings.ToLower(b.Email))
-	b.Password = strings.TrimSpace(b.Password)
+	b.Password = password.Normalize(b.Password)
+}
+
+type ChangePasswordBody struct {
+	CurrentPassword string `json:"currentPassword" validate:"required,max_bytes=256"`
+	NewPassword     string `json:"newPassword"     validate:"required,password,min=8,max_bytes=128"`
+}
+
+func (b *ChangePasswordBody) Normalize() {
+	b.CurrentPassword = password.Normalize(b.CurrentPassword)
+	b.NewPassword = password.Normalize(b.NewPassword)
+}
+
+type ResetPasswordBody struct {
+	Token       string `json:"token"       validate:"required,hexadecimal,len=64"`
+	NewPassword string `json:"newPassword" validate:"required,password,min=8,max_bytes=128"`
+}
+
+func (b *ResetPasswordBody) Normalize() {
+	b.Token = strings.TrimSpace(b.Token)
+	b.NewPassword = password.Normalize(b.NewPassword)
 }
 
 type AuthResponse struct {
+	ID                     string    `json:"id"`
+	Name                   string    `json:"name"`
+	Email                  string    `json:"email"`
+	Roles                  []string  `json:"roles"`
+	CreatedAt              time.Time `json:"createdAt"`
+	PasswordChangeRequired bool      `json:"passwordChangeRequired,omitempty"`
+	Impersonating          bool      `json:"impersonating,omitempty"`
+	ImpersonatorID         string    `json:"impersonatorId,omitempty"`
+}
+
+func NewAuthResponse(user *User) AuthResponse {
+	return AuthResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Roles:     user.Roles,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+type UserIDParams struct {
+	ID string `uri:"id" validate:"required,uuid"`
+}
+
+type SetPlanBody struct {
+	Plan string `json:"plan" validate:"required,max=64"`
+}
+
+func (b *SetPlanBody) Normalize() {
+	b.Plan = strings.TrimSpace(b.Plan)
+}
+
+type ListUsersQuery struct {
+	pagination.Query
+
+	Email         string `query:"email"         validate:"omitempty,max=254"`
+	Name          string `query:"name"          validate:"omitempty,max=24"`
+	CreatedAfter  string `query:"createdAfter"  validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedBefore string `query:"createdBefore" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+func (q *ListUsersQuery) Normalize() {
+	q.Email = strings.TrimSpace(strings.ToLower(q.Email))
+	q.Name = strings.TrimSpace(q.Name)
+}
+
+// Filter is only valid after validation, which guarantees the dates parse.
+func (q *ListUsersQuery) Filter() Filter {
+	filter := Filter{
+		Email: q.Email,
+		Name:  q.Name,
+	}
+
+	if q.CreatedAfter != "" {
+		filter.CreatedAfter, _ = time.Parse(time.RFC3339, q.CreatedAfter)
+	}
+
+	if q.CreatedBefore != "" {
+		filter.CreatedBefore, _ = time.Parse(time.RFC3339, q.CreatedBefore)
+	}
+
+	return filter
+}
+
+type SearchUsersQuery struct {
+	pagination.Query
+
+	Q             string `query:"q"             validate:"omitempty,max=254"`
+	Role          string `query:"role"          validate:"omitempty,max=32"`
+	Disabled      *bool  `query:"disabled"`
+	CreatedAfter  string `query:"createdAfter"  validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedBefore string `query:"createdBefore" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Sort          string `query:"sort"          validate:"omitempty,oneof=createdAt -createdAt name -name email -email"`
+}
+
+// searchSorts maps the sort values clients may use to indexed fields. A
+// leading "-" sorts in descending order.
+var searchSorts = map[string]pagination.Sort{
+	"createdAt":  {Field: "created_at", Type: bson.TypeDateTime},
+	"-createdAt": {Field: "created_at", Type: bson.TypeDateTime, Descending: true},
+	"name":       {Field: "name_lower", Type: bson.TypeString},
+	"-name":      {Field: "name_lower", Type: bson.TypeString, Descending: true},
+	"email":      {Field: "email", Type: bson.TypeString},
+	"-email":     {Field: "email", Type: bson.TypeString, Descending: true},
+}
+
+func (q *SearchUsersQuery) Normalize() {
+	q.Q = strings.TrimSpace(q.Q)
+	q.Role = strings.TrimSpace(q.Role)
+
+	if q.Sort == "" {
+		q.Sort = "-createdAt"
+	}
+}
+
+func (q *SearchUsersQuery) Filter() Filter {
+	filter := Filter{
+		Prefix:   q.Q,
+		Role:     q.Role,
+		Disabled: q.Disabled,
+	}
+
+	if q.CreatedAfter != "" {
+		filter.CreatedAfter, _ = time.Parse(time.RFC3339, q.CreatedAfter)
+	}
+
+	if q.CreatedBefore != "" {
+		filter.CreatedBefore, _ = time.Parse(time.RFC3339, q.CreatedBefore)
+	}
+
+	return filter
+}
+
+// SortOrder is only valid after validation, which restricts Sort to the
+// keys of searchSorts.
+func (q *SearchUsersQuery) SortOrder() pagination.Sort {
+	return searchSorts[q.Sort]
+}
+
+type AdminUserResponse struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
+	Roles     []string  `json:"roles"`
+	Disabled  bool      `json:"disabled"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
-func NewAuthResponse(user *User) AuthResponse {
-	return AuthResponse{
+func NewAdminUserResponse(user *User) AdminUserResponse {
+	return AdminUserResponse{
 		ID:        user.ID,
 		Name:      user.Name,
 		Email:     user.Email,
+		Roles:     user.Roles,
+		Disabled:  user.Disabled,
 		CreatedAt: user.CreatedAt,
 	}
 }
+
+type PasswordResetResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
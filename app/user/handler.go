@@ -2,27 +2,39 @@ package user
 
 import (
 	"errors"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 
+	"github.com/BurakYs/go-api-example/app/audit"
+	"github.com/BurakYs/go-api-example/app/invite"
 	"github.com/BurakYs/go-api-example/app/session"
 	"github.com/BurakYs/go-api-example/config"
 	"github.com/BurakYs/go-api-example/httperror"
 	"github.com/BurakYs/go-api-example/middleware"
+	"github.com/BurakYs/go-api-example/util/emaildomain"
 	"github.com/BurakYs/go-api-example/util/rctx"
 )
 
 type Handler struct {
-	svc        *Service
-	sessionSvc *session.Service
-	cookieCfg  *config.CookieConfig
+	svc             *Service
+	sessionSvc      *session.Service
+	auditSvc        *audit.Service
+	inviteSvc       *invite.Service
+	cookieCfg       *config.CookieConfig
+	registrationCfg *config.RegistrationConfig
 }
 
-func NewHandler(svc *Service, sessionSvc *session.Service, cookieCfg *config.CookieConfig) *Handler {
+func NewHandler(svc *Service, sessionSvc *session.Service, auditSvc *audit.Service, inviteSvc *invite.Service, cookieCfg *config.CookieConfig, registrationCfg *config.RegistrationConfig) *Handler {
 	return &Handler{
-		svc:        svc,
-		sessionSvc: sessionSvc,
-		cookieCfg:  cookieCfg,
+		svc:             svc,
+		sessionSvc:      sessionSvc,
+		auditSvc:        auditSvc,
+		inviteSvc:       inviteSvc,
+		cookieCfg:       cookieCfg,
+		registrationCfg: registrationCfg,
 	}
 }
 
@@ -32,17 +44,40 @@ func (h *Handler) Register(c fiber.Ctx) error {
 		return err
 	}
 
-	user, err := h.svc.Register(c, body.Name, body.Email, body.Password)
+	err = h.checkPasswordPolicy(c, "password", body.Password, body.Name, body.Email)
+	if err != nil {
+		return err
+	}
+
+	usedInvite, err := h.useInvite(c, body.Invite)
 	if err != nil {
 		return err
 	}
 
+	user, err := h.svc.Register(c, body.Name, body.Email, body.Password)
+	if err != nil {
+		if usedInvite != nil {
+			_ = h.inviteSvc.Release(c, usedInvite.ID)
+		}
+
+		var rejected *emaildomain.RejectedError
+		if errors.As(err, &rejected) {
+			return middleware.NewValidationError(middleware.ValidationFailure{
+				Location: "body",
+				Field:    "email",
+				Message:  rejected.Message,
+			})
+		}
+
+		return h.withRetryAfter(c, err)
+	}
+
 	sessionID, err := h.sessionSvc.Create(c, user.ID)
 	if err != nil {
 		return err
 	}
 
-	h.setSessionCookie(c, sessionID)
+	setSessionCookie(c, h.cookieCfg, sessionID, h.cookieCfg.Expiration)
 	return c.JSON(NewAuthResponse(user))
 }
 
@@ -54,16 +89,26 @@ func (h *Handler) Login(c fiber.Ctx) error {
 
 	user, err := h.svc.Login(c, body.Email, body.Password)
 	if err != nil {
-		return err
+		return h.withRetryAfter(c, err)
 	}
 
-	sessionID, err := h.sessionSvc.Create(c, user.ID)
+	expired := h.svc.PasswordExpired(user)
+
+	var sessionID string
+	if expired {
+		sessionID, err = h.sessionSvc.CreateRestricted(c, user.ID)
+	} else {
+		sessionID, err = h.sessionSvc.Create(c, user.ID)
+	}
 	if err != nil {
 		return err
 	}
 
-	h.setSessionCookie(c, sessionID)
-	return c.JSON(NewAuthResponse(user))
+	setSessionCookie(c, h.cookieCfg, sessionID, h.cookieCfg.Expiration)
+
+	response := NewAuthResponse(user)
+	response.PasswordChangeRequired = expired
+	return c.JSON(response)
 }
 
 func (h *Handler) Me(c fiber.Ctx) error {
@@ -77,33 +122,214 @@ func (h *Handler) Me(c fiber.Ctx) error {
 		return err
 	}
 
-	return c.JSON(NewAuthResponse(user))
+	response := NewAuthResponse(user)
+	response.PasswordChangeRequired = h.svc.PasswordExpired(user)
+	response.ImpersonatorID = rctx.GetImpersonatorID(c)
+	response.Impersonating = response.ImpersonatorID != ""
+	return c.JSON(response)
+}
+
+func (h *Handler) ChangePassword(c fiber.Ctx) error {
+	body, err := middleware.ValidateBody[ChangePasswordBody](c)
+	if err != nil {
+		return err
+	}
+
+	userID := rctx.GetUserID(c)
+
+	user, err := h.svc.GetByID(c, userID)
+	if err != nil {
+		return err
+	}
+
+	err = h.checkPasswordPolicy(c, "newPassword", body.NewPassword, user.Name, user.Email)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.ChangePassword(c, user, body.CurrentPassword, body.NewPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrIncorrectPassword):
+			return newPasswordValidationError("currentPassword", ErrIncorrectPassword.Message)
+		case errors.Is(err, ErrPasswordReused):
+			return newPasswordValidationError("newPassword", ErrPasswordReused.Message)
+		}
+		return h.withRetryAfter(c, err)
+	}
+
+	err = h.sessionSvc.RevokeAllForUser(c, userID)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := h.sessionSvc.Create(c, userID)
+	if err != nil {
+		return err
+	}
+
+	setSessionCookie(c, h.cookieCfg, sessionID, h.cookieCfg.Expiration)
+	return c.SendStatus(fiber.StatusNoContent)
 }
 
+func (h *Handler) ResetPassword(c fiber.Ctx) error {
+	body, err := middleware.ValidateBody[ResetPasswordBody](c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.svc.GetByPasswordResetToken(c, body.Token)
+	if err != nil {
+		return err
+	}
+
+	err = h.checkPasswordPolicy(c, "newPassword", body.NewPassword, user.Name, user.Email)
+	if err != nil {
+		return err
+	}
+
+	err = h.svc.ResetPassword(c, user, body.Token, body.NewPassword)
+	if err != nil {
+		if errors.Is(err, ErrPasswordReused) {
+			return newPasswordValidationError("newPassword", ErrPasswordReused.Message)
+		}
+		return h.withRetryAfter(c, err)
+	}
+
+	err = h.sessionSvc.RevokeAllForUser(c, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Logout ends an impersonation session by returning to the admin's own
+// session instead of signing out completely.
 func (h *Handler) Logout(c fiber.Ctx) error {
+	if rctx.GetImpersonatorID(c) != "" {
+		return h.stopImpersonation(c)
+	}
+
 	_ = h.sessionSvc.Delete(c, rctx.GetSessionID(c))
-	h.clearSessionCookie(c)
+	clearSessionCookie(c, h.cookieCfg)
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
-func (h *Handler) setSessionCookie(c fiber.Ctx, value string) {
+func (h *Handler) StopImpersonation(c fiber.Ctx) error {
+	if rctx.GetImpersonatorID(c) == "" {
+		return httperror.New(fiber.StatusBadRequest, "Not impersonating a user")
+	}
+
+	return h.stopImpersonation(c)
+}
+
+func (h *Handler) stopImpersonation(c fiber.Ctx) error {
+	sess, err := h.sessionSvc.Get(c, rctx.GetSessionID(c))
+	if err != nil {
+		return err
+	}
+
+	err = h.sessionSvc.Delete(c, rctx.GetSessionID(c))
+	if err != nil {
+		return err
+	}
+
+	err = h.auditSvc.Record(c, &audit.Entry{
+		Action:   audit.ActionImpersonationStop,
+		ActorID:  sess.ImpersonatorID,
+		TargetID: sess.UserID,
+		IP:       rctx.GetClientIP(c),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = h.sessionSvc.Get(c, sess.ImpersonatorSessionID)
+	if err != nil {
+		if !errors.Is(err, session.ErrNotFound) {
+			return err
+		}
+
+		clearSessionCookie(c, h.cookieCfg)
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	setSessionCookie(c, h.cookieCfg, sess.ImpersonatorSessionID, h.cookieCfg.Expiration)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) checkPasswordPolicy(c fiber.Ctx, field, password, name, email string) error {
+	messages, err := h.svc.CheckPasswordPolicy(c, password, name, email)
+	if err != nil {
+		return err
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return newPasswordValidationError(field, messages...)
+}
+
+// useInvite takes one use of the invite when registration is invite only.
+// It returns nil without an error when registration is open.
+func (h *Handler) useInvite(c fiber.Ctx, code string) (*invite.Invite, error) {
+	if !h.registrationCfg.InviteOnly {
+		return nil, nil
+	}
+
+	if code == "" {
+		return nil, middleware.NewValidationError(middleware.ValidationFailure{
+			Location: "body",
+			Field:    "invite",
+			Message:  "An invite is required to register",
+		})
+	}
+
+	return h.inviteSvc.Use(c, code)
+}
+
+func newPasswordValidationError(field string, messages ...string) error {
+	failures := make([]middleware.ValidationFailure, len(messages))
+	for i, message := range messages {
+		failures[i] = middleware.ValidationFailure{
+			Location: "body",
+			Field:    field,
+			Message:  message,
+		}
+	}
+
+	return middleware.NewValidationError(failures...)
+}
+
+func (h *Handler) withRetryAfter(c fiber.Ctx, err error) error {
+	if errors.Is(err, ErrServiceBusy) {
+		seconds := int(math.Ceil(h.svc.RetryAfter().Seconds()))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(seconds, 1)))
+	}
+
+	return err
+}
+
+func setSessionCookie(c fiber.Ctx, cfg *config.CookieConfig, value string, expiration time.Duration) {
 	c.Cookie(&fiber.Cookie{
-		Name:     h.cookieCfg.Name,
+		Name:     cfg.Name,
 		Value:    value,
-		MaxAge:   int(h.cookieCfg.Expiration.Seconds()),
+		MaxAge:   int(expiration.Seconds()),
 		HTTPOnly: true,
-		Secure:   h.cookieCfg.Secure,
-		SameSite: h.cookieCfg.SameSite,
+		Secure:   cfg.Secure,
+		SameSite: cfg.SameSite,
 	})
 }
 
-func (h *Handler) clearSessionCookie(c fiber.Ctx) {
+func clearSessionCookie(c fiber.Ctx, cfg *config.CookieConfig) {
 	c.Cookie(&fiber.Cookie{
-		Name:     h.cookieCfg.Name,
+		Name:     cfg.Name,
 		Value:    "",
 		MaxAge:   -1,
 		HTTPOnly: true,
-		Secure:   h.cookieCfg.Secure,
-		SameSite: h.cookieCfg.SameSite,
+		Secure:   cfg.Secure,
+		SameSite: cfg.SameSite,
 	})
 }
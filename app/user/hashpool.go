@@ -0,0 +1,74 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"time"
+)
+
+var (
+	hashQueueDepth = expvar.NewInt("password_hash_queue_depth")
+	hashInFlight   = expvar.NewInt("password_hash_in_flight")
+	hashRejected   = expvar.NewInt("password_hash_rejected_total")
+	hashLatency    = expvar.NewMap("password_hash_latency")
+)
+
+// hashPool bounds the number of concurrent password hashing operations.
+// Argon2 is memory-hard, so running it unbounded on every request goroutine
+// lets a burst of logins exhaust memory.
+type hashPool struct {
+	workers      chan struct{}
+	queue        chan struct{}
+	queueTimeout time.Duration
+}
+
+func newHashPool(workers, queueSize int, queueTimeout time.Duration) *hashPool {
+	return &hashPool{
+		workers:      make(chan struct{}, workers),
+		queue:        make(chan struct{}, workers+queueSize),
+		queueTimeout: queueTimeout,
+	}
+}
+
+// run executes fn once a worker is free. It returns ErrServiceBusy without
+// running fn if the queue is full or no worker frees up before the queue
+// timeout or ctx expires.
+func (p *hashPool) run(ctx context.Context, fn func()) error {
+	select {
+	case p.queue <- struct{}{}:
+	default:
+		hashRejected.Add(1)
+		return ErrServiceBusy
+	}
+	defer func() { <-p.queue }()
+
+	ctx, cancel := context.WithTimeout(ctx, p.queueTimeout)
+	defer cancel()
+
+	hashQueueDepth.Add(1)
+	select {
+	case p.workers <- struct{}{}:
+		hashQueueDepth.Add(-1)
+	case <-ctx.Done():
+		hashQueueDepth.Add(-1)
+		hashRejected.Add(1)
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrServiceBusy
+		}
+		return ctx.Err()
+	}
+	defer func() { <-p.workers }()
+
+	hashInFlight.Add(1)
+	defer hashInFlight.Add(-1)
+
+	start := time.Now()
+	fn()
+
+	hashLatency.Add("count", 1)
+	hashLatency.Add("total_us", time.Since(start).Microseconds())
+
+	return nil
+}
@@ -9,15 +9,49 @@ import (
 )
 
 type User struct {
-	ID        string    `json:"id"        bson:"_id"`
-	Name      string    `json:"name"      bson:"name"`
-	Email     string    `json:"email"     bson:"email"`
-	Password  string    `json:"-"         bson:"password"`
-	CreatedAt time.Time `json:"createdAt" bson:"created_at"`
+	ID                string         `json:"id"        bson:"_id"`
+	TenantID          string         `json:"-"         bson:"tenant_id"`
+	Name              string         `json:"name"      bson:"name"`
+	NameLower         string         `json:"-"         bson:"name_lower"`
+	Email             string         `json:"email"     bson:"email"`
+	Roles             []string       `json:"roles"     bson:"roles,omitempty"`
+	Plan              string         `json:"plan"      bson:"plan,omitempty"`
+	Password          string         `json:"-"         bson:"password"`
+	PasswordVersion   int            `json:"-"         bson:"password_version,omitempty"`
+	PasswordHistory   []string       `json:"-"         bson:"password_history,omitempty"`
+	PasswordChangedAt time.Time      `json:"-"         bson:"password_changed_at,omitempty"`
+	PasswordReset     *PasswordReset `json:"-"         bson:"password_reset,omitempty"`
+	Disabled          bool           `json:"disabled"  bson:"disabled,omitempty"`
+	CreatedAt         time.Time      `json:"createdAt" bson:"created_at"`
 }
 
+type PasswordReset struct {
+	TokenHash string    `bson:"token_hash"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// Filter narrows down user listings. Zero values are ignored.
+type Filter struct {
+	Email         string
+	Name          string
+	Prefix        string
+	Role          string
+	Disabled      *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+const DefaultRole = "user"
+
 var (
-	ErrAlreadyExists      = httperror.New(fiber.StatusConflict, "This email is already registered")
-	ErrInvalidCredentials = httperror.New(fiber.StatusUnauthorized, "Invalid email or password")
-	ErrNotFound           = httperror.New(fiber.StatusNotFound, "User not found")
+	ErrAlreadyExists          = httperror.New(fiber.StatusConflict, "This email is already registered")
+	ErrInvalidCredentials     = httperror.New(fiber.StatusUnauthorized, "Invalid email or password")
+	ErrNotFound               = httperror.New(fiber.StatusNotFound, "User not found")
+	ErrPasswordReused         = httperror.New(fiber.StatusBadRequest, "This password was used recently")
+	ErrIncorrectPassword      = httperror.New(fiber.StatusBadRequest, "Current password is incorrect")
+	ErrAccountDisabled        = httperror.New(fiber.StatusForbidden, "This account is disabled")
+	ErrInvalidResetToken      = httperror.New(fiber.StatusBadRequest, "Invalid or expired password reset token")
+	ErrSelfImpersonation      = httperror.New(fiber.StatusBadRequest, "You cannot impersonate yourself")
+	ErrImpersonationForbidden = httperror.New(fiber.StatusForbidden, "You cannot impersonate a user with permissions you don't have")
+	ErrServiceBusy            = httperror.New(fiber.StatusServiceUnavailable, "Service is busy, please try again later")
 )
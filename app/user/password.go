@@ -0,0 +1,105 @@
+package user
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sixcolors/argon2id"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/BurakYs/go-api-example/config"
+)
+
+// currentPasswordVersion is stored alongside hashes created from normalized
+// passwords. Older hashes were created from whitespace-trimmed passwords and
+// stay verifiable until they are upgraded on the next successful login.
+const currentPasswordVersion = 1
+
+type passwordHasher struct {
+	params *argon2id.Params
+}
+
+func newPasswordHasher(cfg *config.PasswordConfig) *passwordHasher {
+	return &passwordHasher{
+		params: &argon2id.Params{
+			Memory:      cfg.Memory,
+			Iterations:  cfg.Iterations,
+			Parallelism: cfg.Parallelism,
+			SaltLength:  cfg.SaltLength,
+			KeyLength:   cfg.KeyLength,
+		},
+	}
+}
+
+func (h *passwordHasher) hash(password []byte) (string, error) {
+	b, err := argon2id.GenerateFromPassword(password, h.params)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func (h *passwordHasher) compare(hashed, password []byte) bool {
+	if isBcryptHash(hashed) {
+		return bcrypt.CompareHashAndPassword(hashed, password) == nil
+	}
+
+	return argon2id.CompareHashAndPassword(hashed, password) == nil
+}
+
+// needsRehash reports whether the hash was created with a different algorithm
+// or weaker parameters than the ones currently configured.
+func (h *passwordHasher) needsRehash(hashed []byte) bool {
+	if isBcryptHash(hashed) {
+		return true
+	}
+
+	params, err := decodeArgon2Params(string(hashed))
+	if err != nil {
+		return true
+	}
+
+	return params.Memory < h.params.Memory ||
+		params.Iterations < h.params.Iterations ||
+		params.Parallelism < h.params.Parallelism ||
+		params.SaltLength < h.params.SaltLength ||
+		params.KeyLength < h.params.KeyLength
+}
+
+func isBcryptHash(hashed []byte) bool {
+	s := string(hashed)
+	return strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") || strings.HasPrefix(s, "$2y$")
+}
+
+// decodeArgon2Params parses a PHC formatted hash such as
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func decodeArgon2Params(hashed string) (*argon2id.Params, error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errors.New("invalid argon2id hash format")
+	}
+
+	var params argon2id.Params
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.Strict().DecodeString(parts[4])
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.RawStdEncoding.Strict().DecodeString(parts[5])
+	if err != nil {
+		return nil, err
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return &params, nil
+}
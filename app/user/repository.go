@@ -3,12 +3,17 @@ package user
 import (
 	"context"
 	"errors"
+	"regexp"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 
 	"github.com/BurakYs/go-api-example/database"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/tenant"
 )
 
 type Repository struct {
@@ -22,7 +27,14 @@ func NewRepository(db *database.DB) *Repository {
 }
 
 func (r *Repository) Create(ctx context.Context, user *User) error {
-	_, err := r.collection.InsertOne(ctx, user)
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	user.TenantID = tenantID
+
+	_, err = r.collection.InsertOne(ctx, user)
 	if err != nil && mongo.IsDuplicateKeyError(err) {
 		return ErrAlreadyExists
 	}
@@ -38,24 +50,247 @@ func (r *Repository) GetByID(ctx context.Context, id string) (*User, error) {
 	return r.getByFilter(ctx, bson.M{"_id": id})
 }
 
+func (r *Repository) UpdatePassword(ctx context.Context, id, password string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"password":         password,
+			"password_version": currentPasswordVersion,
+		},
+	}
+
+	return r.updateByID(ctx, id, update)
+}
+
+// ChangePassword replaces the password hash and pushes the previous one onto
+// the user's password history, keeping at most historySize entries.
+func (r *Repository) ChangePassword(ctx context.Context, id, password, previous string, historySize int) error {
+	return r.updateByID(ctx, id, passwordUpdate(password, previous, historySize))
+}
+
+// ResetPassword is ChangePassword for a user holding an unexpired reset
+// token matching tokenHash. Since the update unsets the token it matches at
+// most once, and ErrNotFound is returned for every other attempt.
+func (r *Repository) ResetPassword(ctx context.Context, id, tokenHash, password, previous string, historySize int) error {
+	filter, err := tenant.Scope(ctx, bson.M{
+		"_id":                       id,
+		"password_reset.token_hash": tokenHash,
+		"password_reset.expires_at": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, passwordUpdate(password, previous, historySize))
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func passwordUpdate(password, previous string, historySize int) bson.M {
+	update := bson.M{
+		"$set": bson.M{
+			"password":            password,
+			"password_version":    currentPasswordVersion,
+			"password_changed_at": time.Now(),
+		},
+		"$unset": bson.M{
+			"password_reset": "",
+		},
+	}
+
+	if historySize > 0 {
+		update["$push"] = bson.M{
+			"password_history": bson.M{
+				"$each":  bson.A{previous},
+				"$slice": -historySize,
+			},
+		}
+	}
+
+	return update
+}
+
+func (r *Repository) GetByPasswordResetToken(ctx context.Context, tokenHash string) (*User, error) {
+	return r.getByFilter(ctx, bson.M{"password_reset.token_hash": tokenHash})
+}
+
+func (r *Repository) List(ctx context.Context, filter Filter, sort pagination.Sort, page pagination.Query) ([]*User, string, error) {
+	query, err := tenant.Scope(ctx, r.buildFilter(filter))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pagination.Find[User](ctx, r.collection, query, page, sort)
+}
+
+func (r *Repository) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"disabled": disabled}})
+}
+
+func (r *Repository) SetPlan(ctx context.Context, id, plan string) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"plan": plan}})
+}
+
+func (r *Repository) SetPasswordReset(ctx context.Context, id string, reset *PasswordReset) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"password_reset": reset}})
+}
+
 func (r *Repository) CreateIndexes(ctx context.Context) error {
 	indexes := []mongo.IndexModel{
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "email", Value: 1},
+			),
+			Options: options.Index().SetUnique(true).SetName("tenant_email_index"),
+		},
+		{
+			// Emails are unique, but sorting by them still needs _id in the
+			// index to avoid sorting in memory.
+			Keys: database.TenantKeys(
+				bson.E{Key: "email", Value: 1},
+				bson.E{Key: "_id", Value: 1},
+			),
+			Options: options.Index().SetName("tenant_email_id_index"),
+		},
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "created_at", Value: -1},
+				bson.E{Key: "_id", Value: -1},
+			),
+			Options: options.Index().SetName("tenant_created_at_index"),
+		},
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "name_lower", Value: 1},
+				bson.E{Key: "_id", Value: 1},
+			),
+			Options: options.Index().SetName("tenant_name_lower_index"),
+		},
+		{
+			Keys: database.TenantKeys(
+				bson.E{Key: "roles", Value: 1},
+			),
+			Options: options.Index().SetName("tenant_roles_index"),
+		},
 		{
 			Keys: bson.D{
-				{Key: "email", Value: 1},
+				{Key: "password_reset.token_hash", Value: 1},
 			},
-			Options: options.Index().SetUnique(true).SetName("email_index"),
+			Options: options.Index().SetSparse(true).SetName("password_reset_token_index"),
 		},
 	}
 
 	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return err
+	}
+
+	// These were replaced by the tenant-prefixed indexes above. The old
+	// unique email index would keep emails unique across all tenants.
+	return database.DropIndexes(ctx, r.collection, "email_index", "created_at_index", "name_lower_index", "roles_index")
+}
+
+// BackfillTenant assigns users created before tenancy to tenantID.
+func (r *Repository) BackfillTenant(ctx context.Context, tenantID string) error {
+	filter := bson.M{"tenant_id": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"tenant_id": tenantID}}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
 	return err
 }
 
-func (r *Repository) getByFilter(ctx context.Context, filter any) (*User, error) {
+// BackfillNameLower sets name_lower on users created before it was stored.
+func (r *Repository) BackfillNameLower(ctx context.Context) error {
+	filter := bson.M{"name_lower": bson.M{"$exists": false}}
+	update := bson.A{
+		bson.M{"$set": bson.M{"name_lower": bson.M{"$toLower": "$name"}}},
+	}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *Repository) buildFilter(filter Filter) bson.M {
+	query := bson.M{}
+
+	if filter.Email != "" {
+		query["email"] = bson.M{"$regex": regexp.QuoteMeta(filter.Email), "$options": "i"}
+	}
+
+	if filter.Name != "" {
+		query["name"] = bson.M{"$regex": regexp.QuoteMeta(filter.Name), "$options": "i"}
+	}
+
+	// Both fields are stored lowercased, so an anchored case-sensitive regex
+	// matches case-insensitively while still using their indexes.
+	if filter.Prefix != "" {
+		prefix := "^" + regexp.QuoteMeta(strings.ToLower(filter.Prefix))
+		query["$or"] = bson.A{
+			bson.M{"name_lower": bson.M{"$regex": prefix}},
+			bson.M{"email": bson.M{"$regex": prefix}},
+		}
+	}
+
+	if filter.Role != "" {
+		query["roles"] = filter.Role
+	}
+
+	if filter.Disabled != nil {
+		if *filter.Disabled {
+			query["disabled"] = true
+		} else {
+			query["disabled"] = bson.M{"$ne": true}
+		}
+	}
+
+	createdAt := bson.M{}
+	if !filter.CreatedAfter.IsZero() {
+		createdAt["$gte"] = filter.CreatedAfter
+	}
+	if !filter.CreatedBefore.IsZero() {
+		createdAt["$lt"] = filter.CreatedBefore
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+
+	return query
+}
+
+func (r *Repository) updateByID(ctx context.Context, id string, update any) error {
+	filter, err := tenant.Scope(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// getByFilter only ever matches users of the tenant in ctx.
+func (r *Repository) getByFilter(ctx context.Context, filter bson.M) (*User, error) {
+	filter, err := tenant.Scope(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
 	var user User
 
-	err := r.collection.FindOne(ctx, filter).Decode(&user)
+	err = r.collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, ErrNotFound
@@ -0,0 +1,175 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/BurakYs/go-api-example/database/dbtest"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/tenant"
+)
+
+const sharedEmail = "same@example.com"
+
+// newTenantRepository returns a repository holding a user with the same email
+// in tenants "a" and "b", with the IDs "user-a" and "user-b".
+func newTenantRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	repo := NewRepository(dbtest.New(t))
+	ctx := context.Background()
+
+	err := repo.CreateIndexes(ctx)
+	if err != nil {
+		t.Fatalf("CreateIndexes: %v", err)
+	}
+
+	for _, tenantID := range []string{"a", "b"} {
+		err = repo.Create(tenant.NewContext(ctx, tenantID), &User{
+			ID:        "user-" + tenantID,
+			Name:      "User " + tenantID,
+			Email:     sharedEmail,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Create in tenant %q: %v", tenantID, err)
+		}
+	}
+
+	return repo
+}
+
+func TestRepositoryTenantIsolation(t *testing.T) {
+	repo := newTenantRepository(t)
+
+	tests := []struct {
+		name    string
+		tenant  string
+		get     func(ctx context.Context) (*User, error)
+		wantID  string
+		wantErr error
+	}{
+		{
+			name:   "email in own tenant",
+			tenant: "a",
+			get:    func(ctx context.Context) (*User, error) { return repo.GetByEmail(ctx, sharedEmail) },
+			wantID: "user-a",
+		},
+		{
+			name:   "same email in other tenant",
+			tenant: "b",
+			get:    func(ctx context.Context) (*User, error) { return repo.GetByEmail(ctx, sharedEmail) },
+			wantID: "user-b",
+		},
+		{
+			name:    "id of other tenant",
+			tenant:  "a",
+			get:     func(ctx context.Context) (*User, error) { return repo.GetByID(ctx, "user-b") },
+			wantErr: ErrNotFound,
+		},
+		{
+			name:   "filter naming other tenant",
+			tenant: "a",
+			get: func(ctx context.Context) (*User, error) {
+				return repo.getByFilter(ctx, bson.M{"tenant_id": "b", "email": sharedEmail})
+			},
+			wantID: "user-a",
+		},
+		{
+			name:    "unknown tenant",
+			tenant:  "c",
+			get:     func(ctx context.Context) (*User, error) { return repo.GetByEmail(ctx, sharedEmail) },
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "no tenant",
+			get:     func(ctx context.Context) (*User, error) { return repo.GetByEmail(ctx, sharedEmail) },
+			wantErr: tenant.ErrMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = tenant.NewContext(ctx, tt.tenant)
+			}
+
+			user, err := tt.get(ctx)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if user.ID != tt.wantID || user.TenantID != tt.tenant {
+				t.Fatalf("got user %q of tenant %q, want %q of tenant %q", user.ID, user.TenantID, tt.wantID, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestRepositoryListTenantIsolation(t *testing.T) {
+	repo := newTenantRepository(t)
+
+	tests := []struct {
+		name    string
+		tenant  string
+		wantIDs []string
+		wantErr error
+	}{
+		{name: "tenant a", tenant: "a", wantIDs: []string{"user-a"}},
+		{name: "tenant b", tenant: "b", wantIDs: []string{"user-b"}},
+		{name: "unknown tenant", tenant: "c"},
+		{name: "no tenant", wantErr: tenant.ErrMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = tenant.NewContext(ctx, tt.tenant)
+			}
+
+			users, _, err := repo.List(ctx, Filter{Email: sharedEmail}, pagination.NewestFirst, pagination.Query{})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(users) != len(tt.wantIDs) {
+				t.Fatalf("got %d users, want %d", len(users), len(tt.wantIDs))
+			}
+
+			for i, user := range users {
+				if user.ID != tt.wantIDs[i] {
+					t.Fatalf("got user %q, want %q", user.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoryCreateWithoutTenant(t *testing.T) {
+	repo := NewRepository(dbtest.New(t))
+
+	err := repo.Create(context.Background(), &User{ID: "user", Email: sharedEmail})
+	if !errors.Is(err, tenant.ErrMissing) {
+		t.Fatalf("got error %v, want %v", err, tenant.ErrMissing)
+	}
+}
@@ -2,25 +2,69 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/sixcolors/argon2id"
+
+	"github.com/BurakYs/go-api-example/config"
+	"github.com/BurakYs/go-api-example/util/emaildomain"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/password"
 )
 
 type Service struct {
-	repo *Repository
+	repo   *Repository
+	hasher *passwordHasher
+	pool   *hashPool
+	policy *password.Policy
+	emails *emaildomain.Policy
+
+	historySize     int
+	maxAge          time.Duration
+	resetExpiration time.Duration
 }
 
-func NewService(repo *Repository) *Service {
+func NewService(repo *Repository, passwordCfg *config.PasswordConfig, emailCfg *config.EmailConfig) *Service {
+	workers := passwordCfg.HashWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	policy := &password.Policy{
+		MinScore:         passwordCfg.MinScore,
+		MinCharClasses:   passwordCfg.MinCharClasses,
+		DisallowUserInfo: passwordCfg.DisallowUserInfo,
+	}
+	if passwordCfg.BreachedHashesDir != "" {
+		policy.Breached = password.NewBreachedList(passwordCfg.BreachedHashesDir)
+	}
+
 	return &Service{
-		repo: repo,
+		repo:   repo,
+		hasher: newPasswordHasher(passwordCfg),
+		pool:   newHashPool(workers, passwordCfg.HashQueueSize, passwordCfg.HashQueueTimeout),
+		policy: policy,
+		emails: emaildomain.NewPolicy(emailCfg.AllowedDomains, emailCfg.DeniedDomains, emailCfg.BlockDisposable, emailCfg.DisposableDomains),
+
+		historySize:     passwordCfg.HistorySize,
+		maxAge:          passwordCfg.MaxAge,
+		resetExpiration: passwordCfg.ResetExpiration,
 	}
 }
 
 func (s *Service) Register(ctx context.Context, name, email, password string) (*User, error) {
-	_, err := s.repo.GetByEmail(ctx, email)
+	err := s.CheckEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.repo.GetByEmail(ctx, email)
 	if err == nil {
 		return nil, ErrAlreadyExists
 	}
@@ -28,7 +72,7 @@ func (s *Service) Register(ctx context.Context, name, email, password string) (*
 		return nil, err
 	}
 
-	hashed, err := s.hashPassword([]byte(password))
+	hashed, err := s.hashPassword(ctx, password)
 	if err != nil {
 		return nil, err
 	}
@@ -38,12 +82,17 @@ func (s *Service) Register(ctx context.Context, name, email, password string) (*
 		return nil, err
 	}
 
+	now := time.Now()
 	user := &User{
-		ID:        userID,
-		Name:      name,
-		Email:     email,
-		Password:  hashed,
-		CreatedAt: time.Now(),
+		ID:                userID,
+		Name:              name,
+		NameLower:         strings.ToLower(name),
+		Email:             email,
+		Roles:             []string{DefaultRole},
+		Password:          hashed,
+		PasswordVersion:   currentPasswordVersion,
+		PasswordChangedAt: now,
+		CreatedAt:         now,
 	}
 
 	err = s.repo.Create(ctx, user)
@@ -63,18 +112,168 @@ func (s *Service) Login(ctx context.Context, email, password string) (*User, err
 		return nil, err
 	}
 
-	match := s.comparePasswords([]byte(user.Password), []byte(password))
-	if !match {
+	matched, ok, err := s.verifyPassword(ctx, user, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
 		return nil, ErrInvalidCredentials
 	}
 
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	// The variant that matched is rehashed, so whitespace around a password
+	// that only matched once trimmed doesn't become part of it.
+	if user.PasswordVersion < currentPasswordVersion || s.hasher.needsRehash([]byte(user.Password)) {
+		s.upgradePasswordHash(ctx, user, matched)
+	}
+
 	return user, nil
 }
 
+// ChangePassword verifies the current password of user and replaces it,
+// rejecting passwords that match the current one or any kept in the history.
+func (s *Service) ChangePassword(ctx context.Context, user *User, current, newPassword string) error {
+	_, ok, err := s.verifyPassword(ctx, user, current)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrIncorrectPassword
+	}
+
+	hashed, err := s.hashNewPassword(ctx, user, newPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.ChangePassword(ctx, user.ID, hashed, user.Password, s.historySize-1)
+}
+
+// PasswordExpired reports whether the user has to change their password
+// before getting an unrestricted session.
+func (s *Service) PasswordExpired(user *User) bool {
+	if s.maxAge <= 0 {
+		return false
+	}
+
+	changedAt := user.PasswordChangedAt
+	if changedAt.IsZero() {
+		changedAt = user.CreatedAt
+	}
+
+	return time.Since(changedAt) > s.maxAge
+}
+
+// CheckPasswordPolicy returns the policy rules the password violates for a
+// user with the given name and email.
+func (s *Service) CheckPasswordPolicy(ctx context.Context, password, name, email string) ([]string, error) {
+	return s.policy.Check(ctx, password, name, email)
+}
+
+// CheckEmail returns an *emaildomain.RejectedError if the domain of email may
+// not be used for an account. Only Register calls it, as there is no way to
+// change an email yet. A flow added to change one must call it too.
+func (s *Service) CheckEmail(email string) error {
+	return s.emails.Check(email)
+}
+
 func (s *Service) GetByID(ctx context.Context, id string) (*User, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
+func (s *Service) List(ctx context.Context, filter Filter, sort pagination.Sort, page pagination.Query) ([]*User, string, error) {
+	return s.repo.List(ctx, filter, sort, page)
+}
+
+func (s *Service) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	return s.repo.SetDisabled(ctx, id, disabled)
+}
+
+func (s *Service) SetPlan(ctx context.Context, id, plan string) error {
+	return s.repo.SetPlan(ctx, id, plan)
+}
+
+// CreatePasswordResetToken returns a single use token that lets the user set
+// a new password without knowing the current one. Only its hash is stored.
+func (s *Service) CreatePasswordResetToken(ctx context.Context, id string) (string, time.Time, error) {
+	bytes := make([]byte, 32)
+	_, _ = rand.Read(bytes)
+	token := hex.EncodeToString(bytes)
+
+	expiresAt := time.Now().Add(s.resetExpiration)
+
+	err := s.repo.SetPasswordReset(ctx, id, &PasswordReset{
+		TokenHash: hashResetToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+func (s *Service) GetByPasswordResetToken(ctx context.Context, token string) (*User, error) {
+	user, err := s.repo.GetByPasswordResetToken(ctx, hashResetToken(token))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrInvalidResetToken
+		}
+		return nil, err
+	}
+
+	if user.PasswordReset == nil || time.Now().After(user.PasswordReset.ExpiresAt) {
+		return nil, ErrInvalidResetToken
+	}
+
+	return user, nil
+}
+
+// ResetPassword sets a new password for a user obtained through
+// GetByPasswordResetToken and consumes the token. The token is only consumed
+// together with the password change, so of concurrent requests using it
+// just one succeeds.
+func (s *Service) ResetPassword(ctx context.Context, user *User, token, newPassword string) error {
+	hashed, err := s.hashNewPassword(ctx, user, newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.ResetPassword(ctx, user.ID, hashResetToken(token), hashed, user.Password, s.historySize-1)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	return nil
+}
+
+// hashNewPassword hashes newPassword, rejecting it if it matches the current
+// password of user or one kept in the history.
+func (s *Service) hashNewPassword(ctx context.Context, user *User, newPassword string) (string, error) {
+	// The current password counts towards the history size, so only the
+	// historySize-1 passwords before it are kept in the history.
+	previous := append([]string{user.Password}, user.PasswordHistory...)
+	previous = previous[:min(len(previous), max(s.historySize, 1))]
+
+	for _, hashed := range previous {
+		reused, err := s.comparePasswords(ctx, hashed, newPassword)
+		if err != nil {
+			return "", err
+		}
+		if reused {
+			return "", ErrPasswordReused
+		}
+	}
+
+	return s.hashPassword(ctx, newPassword)
+}
+
 func (s *Service) generateID() (string, error) {
 	id, err := uuid.NewV7()
 	if err != nil {
@@ -84,15 +283,76 @@ func (s *Service) generateID() (string, error) {
 	return id.String(), nil
 }
 
-func (s *Service) hashPassword(password []byte) (string, error) {
-	b, err := argon2id.GenerateFromPassword(password, nil)
+func (s *Service) hashPassword(ctx context.Context, password string) (string, error) {
+	var hashed string
+	var hashErr error
+
+	err := s.pool.run(ctx, func() {
+		hashed, hashErr = s.hasher.hash([]byte(password))
+	})
 	if err != nil {
 		return "", err
 	}
 
-	return string(b), nil
+	return hashed, hashErr
+}
+
+// verifyPassword checks the password against the user's current hash. Hashes
+// from before passwords were normalized were created from trimmed input, so
+// those are also checked against the trimmed password. It returns the
+// variant of password that matched.
+func (s *Service) verifyPassword(ctx context.Context, user *User, password string) (string, bool, error) {
+	match, err := s.comparePasswords(ctx, user.Password, password)
+	if err != nil || match {
+		return password, match, err
+	}
+
+	trimmed := strings.TrimSpace(password)
+	if user.PasswordVersion >= currentPasswordVersion || trimmed == password {
+		return "", false, nil
+	}
+
+	match, err = s.comparePasswords(ctx, user.Password, trimmed)
+	return trimmed, match, err
+}
+
+func (s *Service) comparePasswords(ctx context.Context, hashed, password string) (bool, error) {
+	var match bool
+
+	err := s.pool.run(ctx, func() {
+		match = s.hasher.compare([]byte(hashed), []byte(password))
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return match, nil
+}
+
+// RetryAfter is how long clients rejected with ErrServiceBusy should wait
+// before retrying.
+func (s *Service) RetryAfter() time.Duration {
+	return s.pool.queueTimeout
+}
+
+// upgradePasswordHash is best effort: a failure here must not block a login
+// that has already been verified, the hash will be upgraded on a later login.
+func (s *Service) upgradePasswordHash(ctx context.Context, user *User, password string) {
+	hashed, err := s.hashPassword(ctx, password)
+	if err != nil {
+		return
+	}
+
+	err = s.repo.UpdatePassword(ctx, user.ID, hashed)
+	if err != nil {
+		return
+	}
+
+	user.Password = hashed
+	user.PasswordVersion = currentPasswordVersion
 }
 
-func (s *Service) comparePasswords(hashed, password []byte) bool {
-	return argon2id.CompareHashAndPassword(hashed, password) == nil
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
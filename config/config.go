@@ -1,9 +1,17 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v11"
+
+	"github.com/BurakYs/go-api-example/util/emaildomain"
 )
 
 type Config struct {
@@ -12,11 +20,50 @@ type Config struct {
 	Database  DatabaseConfig
 	Redis     RedisConfig
 	RateLimit RateLimitConfig
+	Password  PasswordConfig
+	RBAC      RBACConfig
+
+	Impersonation ImpersonationConfig
+	Organization  OrganizationConfig
+	Tenancy       TenancyConfig
+	Registration  RegistrationConfig
+	Email         EmailConfig
+	Proxy         ProxyConfig
+	IPAccess      IPAccessConfig
+	Pagination    PaginationConfig
 }
 
 type AppConfig struct {
 	Port     string `env:"PORT"      envDefault:"8080"`
 	LogLevel string `env:"LOG_LEVEL" envDefault:"info"`
+	Metrics  bool   `env:"METRICS"   envDefault:"false"`
+}
+
+type ProxyConfig struct {
+	// TrustedProxies lists the IPs or CIDR ranges of the proxies in front of
+	// the app. Header is only read from requests coming through them.
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:","`
+	Header         string   `env:"PROXY_HEADER"    envDefault:"X-Forwarded-For"`
+
+	TrustedPrefixes []netip.Prefix `env:"-"`
+}
+
+type PaginationConfig struct {
+	// CursorSecret signs pagination cursors. Without it a random key is
+	// used, so cursors only work on the instance that issued them.
+	CursorSecret string `env:"PAGINATION_CURSOR_SECRET"`
+}
+
+type IPAccessConfig struct {
+	// RefreshInterval is how often the allow and deny lists are reloaded
+	// from Redis, and so how long changes take to reach other instances.
+	RefreshInterval time.Duration `env:"IP_ACCESS_REFRESH_INTERVAL" envDefault:"10s"`
+
+	// IPs rejected by rate limits BanThreshold times within BanWindow are
+	// banned for BanDuration. A BanThreshold of 0 disables automatic bans.
+	BanThreshold int           `env:"IP_BAN_THRESHOLD" envDefault:"20"`
+	BanWindow    time.Duration `env:"IP_BAN_WINDOW"    envDefault:"10m"`
+	BanDuration  time.Duration `env:"IP_BAN_DURATION"  envDefault:"1h"`
 }
 
 type CookieConfig struct {
@@ -27,6 +74,48 @@ type CookieConfig struct {
 	SameSite   string        `env:"COOKIE_SAME_SITE,required"`
 }
 
+type ImpersonationConfig struct {
+	Expiration time.Duration `env:"IMPERSONATION_EXPIRATION" envDefault:"1h"`
+}
+
+type OrganizationConfig struct {
+	InvitationExpiration time.Duration `env:"ORGANIZATION_INVITATION_EXPIRATION" envDefault:"168h"`
+}
+
+type RegistrationConfig struct {
+	InviteOnly bool `env:"REGISTRATION_INVITE_ONLY" envDefault:"false"`
+
+	// InviteSecret signs invite link tokens. Links are disabled without it,
+	// leaving invite codes as the only way to register.
+	InviteSecret string `env:"REGISTRATION_INVITE_SECRET"`
+}
+
+// EmailConfig restricts the email domains accounts may be registered with.
+// It only applies to registration, the only flow that sets a user's email.
+// Organization invitations are not checked, since only registered users can
+// accept them.
+type EmailConfig struct {
+	AllowedDomains        []string `env:"EMAIL_ALLOWED_DOMAINS"           envSeparator:","`
+	DeniedDomains         []string `env:"EMAIL_DENIED_DOMAINS"            envSeparator:","`
+	BlockDisposable       bool     `env:"EMAIL_BLOCK_DISPOSABLE"          envDefault:"true"`
+	DisposableDomainsFile string   `env:"EMAIL_DISPOSABLE_DOMAINS_FILE"`
+
+	// DisposableDomains are read from DisposableDomainsFile, one per line,
+	// and extend the built-in list of disposable domains.
+	DisposableDomains []string `env:"-"`
+}
+
+// TenancyConfig controls how the tenant of a request is resolved. The header
+// wins over the subdomain of BaseDomain, which wins over the tenant of the
+// session. Requests matching none of them use Default, or are rejected when
+// it is empty. The header is only read when set, e.g. to X-Tenant-ID, and
+// must match the tenant of the session if there is one.
+type TenancyConfig struct {
+	Header     string `env:"TENANT_HEADER"`
+	BaseDomain string `env:"TENANT_BASE_DOMAIN"`
+	Default    string `env:"TENANT_DEFAULT"     envDefault:"default"`
+}
+
 type DatabaseConfig struct {
 	Name string `env:"MONGODB_DBNAME,required"`
 	URI  string `env:"MONGODB_URI,required"`
@@ -39,10 +128,120 @@ type RedisConfig struct {
 	DB       int    `env:"REDIS_DB,required"`
 }
 
+// RateLimitConfig configures the rate limits. Like a Limit, a Requests of
+// zero lifts the limit it sets instead of blocking every request.
 type RateLimitConfig struct {
 	Enabled  bool          `env:"RATE_LIMIT_ENABLED"  envDefault:"true"`
 	Requests int           `env:"RATE_LIMIT_REQUESTS" envDefault:"50"`
 	Window   time.Duration `env:"RATE_LIMIT_WINDOW"   envDefault:"60s"`
+
+	// Account limits apply per login identifier on top of the per-IP limit,
+	// so attackers rotating IPs can't keep guessing one account's password.
+	// Every attempt counts, so anyone knowing an email can also lock its
+	// owner out for up to AccountWindow. Keep the window short enough for
+	// that to be tolerable.
+	AccountRequests int           `env:"RATE_LIMIT_ACCOUNT_REQUESTS" envDefault:"10"`
+	AccountWindow   time.Duration `env:"RATE_LIMIT_ACCOUNT_WINDOW"   envDefault:"15m"`
+
+	// Backend is "redis" or "memory". FailurePolicy is "fallback", "open" or
+	// "closed" and applies while Redis is unavailable.
+	Backend       string `env:"RATE_LIMIT_BACKEND"        envDefault:"redis"`
+	FailurePolicy string `env:"RATE_LIMIT_FAILURE_POLICY" envDefault:"fallback"`
+
+	// Concurrency caps the requests each client, and all clients together,
+	// may have in flight on expensive routes. Slots are leased so they free
+	// themselves if an instance dies while holding them.
+	Concurrency       int           `env:"RATE_LIMIT_CONCURRENCY"        envDefault:"2"`
+	GlobalConcurrency int           `env:"RATE_LIMIT_GLOBAL_CONCURRENCY" envDefault:"0"`
+	ConcurrencyLease  time.Duration `env:"RATE_LIMIT_CONCURRENCY_LEASE"  envDefault:"30s"`
+
+	// Headers is "legacy" (X-RateLimit-*), "ietf" (RateLimit and
+	// RateLimit-Policy) or "both".
+	Headers string `env:"RATE_LIMIT_HEADERS" envDefault:"legacy"`
+
+	// Plans maps plan names to the limits of their users. It is read from
+	// PlansFile as a JSON object, e.g. {"free": {"requests": 60, "window":
+	// "1m", "dailyQuota": 1000}}. Users without a plan are on DefaultPlan.
+	PlansFile   string                `env:"RATE_LIMIT_PLANS_FILE"`
+	DefaultPlan string                `env:"RATE_LIMIT_DEFAULT_PLAN" envDefault:"free"`
+	Plans       map[string]PlanLimits `env:"-"`
+}
+
+// PlanLimits are the limits of a plan. Zero requests or quotas are unlimited.
+type PlanLimits struct {
+	Requests     int           `json:"requests"`
+	Window       time.Duration `json:"-"`
+	DailyQuota   int           `json:"dailyQuota"`
+	MonthlyQuota int           `json:"monthlyQuota"`
+}
+
+func (p *PlanLimits) UnmarshalJSON(data []byte) error {
+	type plain PlanLimits
+	var raw struct {
+		plain
+		Window string `json:"window"`
+	}
+
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	*p = PlanLimits(raw.plain)
+	if raw.Window != "" {
+		p.Window, err = time.ParseDuration(raw.Window)
+		if err != nil {
+			return fmt.Errorf("invalid window: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type PasswordConfig struct {
+	Memory      uint32 `env:"PASSWORD_ARGON2_MEMORY"      envDefault:"65536"`
+	Iterations  uint32 `env:"PASSWORD_ARGON2_ITERATIONS"  envDefault:"3"`
+	Parallelism uint8  `env:"PASSWORD_ARGON2_PARALLELISM" envDefault:"2"`
+	SaltLength  uint32 `env:"PASSWORD_ARGON2_SALT_LENGTH" envDefault:"16"`
+	KeyLength   uint32 `env:"PASSWORD_ARGON2_KEY_LENGTH"  envDefault:"32"`
+
+	HashWorkers      int           `env:"PASSWORD_HASH_WORKERS"`
+	HashQueueSize    int           `env:"PASSWORD_HASH_QUEUE_SIZE"    envDefault:"64"`
+	HashQueueTimeout time.Duration `env:"PASSWORD_HASH_QUEUE_TIMEOUT" envDefault:"5s"`
+
+	MinScore          int    `env:"PASSWORD_MIN_SCORE"           envDefault:"2"`
+	MinCharClasses    int    `env:"PASSWORD_MIN_CHAR_CLASSES"    envDefault:"0"`
+	DisallowUserInfo  bool   `env:"PASSWORD_DISALLOW_USER_INFO"  envDefault:"true"`
+	BreachedHashesDir string `env:"PASSWORD_BREACHED_HASHES_DIR"`
+
+	// HistorySize is how many of the latest passwords, including the current
+	// one, can't be reused.
+	HistorySize int           `env:"PASSWORD_HISTORY_SIZE" envDefault:"5"`
+	MaxAge      time.Duration `env:"PASSWORD_MAX_AGE"      envDefault:"0"`
+
+	ResetExpiration time.Duration `env:"PASSWORD_RESET_EXPIRATION" envDefault:"1h"`
+}
+
+type RBACConfig struct {
+	RolesFile string `env:"RBAC_ROLES_FILE"`
+
+	// Roles maps role names to the permissions they grant. It is read from
+	// RolesFile as a JSON object, e.g. {"support": ["users:read"]}.
+	Roles map[string][]string `env:"-"`
+
+	// UserCacheTTL is how long each instance caches the roles of signed in
+	// users, and so how long role changes take to apply to their sessions.
+	UserCacheTTL time.Duration `env:"RBAC_USER_CACHE_TTL" envDefault:"10s"`
+}
+
+var defaultRoles = map[string][]string{
+	"admin": {"*"},
+	"user":  {},
+}
+
+var defaultPlans = map[string]PlanLimits{
+	"free": {Requests: 60, Window: time.Minute, DailyQuota: 1000, MonthlyQuota: 20000},
+	"paid": {Requests: 600, Window: time.Minute, DailyQuota: 50000, MonthlyQuota: 1000000},
 }
 
 func Load() (*Config, error) {
@@ -53,5 +252,163 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	err = config.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	config.RBAC.Roles, err = loadRoles(config.RBAC.RolesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config.RateLimit.Plans, err = loadPlans(config.RateLimit.PlansFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := config.RateLimit.Plans[config.RateLimit.DefaultPlan]; !ok {
+		return nil, fmt.Errorf("default plan %q is not defined", config.RateLimit.DefaultPlan)
+	}
+
+	config.Proxy.TrustedPrefixes, err = parsePrefixes(config.Proxy.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Email.DisposableDomains, err = loadDomains(config.Email.DisposableDomainsFile)
+	if err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+// validate rejects values env.Parse accepts but the app can't work with.
+func (c *Config) validate() error {
+	if c.Password.HashQueueTimeout <= 0 {
+		return fmt.Errorf("PASSWORD_HASH_QUEUE_TIMEOUT must be positive, got %s", c.Password.HashQueueTimeout)
+	}
+
+	limits := []struct {
+		name     string
+		requests int
+		window   time.Duration
+	}{
+		{"RATE_LIMIT_REQUESTS", c.RateLimit.Requests, c.RateLimit.Window},
+		{"RATE_LIMIT_ACCOUNT_REQUESTS", c.RateLimit.AccountRequests, c.RateLimit.AccountWindow},
+	}
+
+	for _, limit := range limits {
+		if limit.requests < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", limit.name, limit.requests)
+		}
+		if limit.requests > 0 && limit.window <= 0 {
+			return fmt.Errorf("%s is set but its window is not positive, got %s", limit.name, limit.window)
+		}
+	}
+
+	// Unknown values would otherwise silently fall back to the defaults of
+	// the rate limiter.
+	options := []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"RATE_LIMIT_HEADERS", c.RateLimit.Headers, []string{"legacy", "ietf", "both"}},
+		{"RATE_LIMIT_BACKEND", c.RateLimit.Backend, []string{"redis", "memory"}},
+		{"RATE_LIMIT_FAILURE_POLICY", c.RateLimit.FailurePolicy, []string{"fallback", "open", "closed"}},
+	}
+
+	for _, option := range options {
+		if !slices.Contains(option.allowed, option.value) {
+			return fmt.Errorf("%s must be one of %s, got %q", option.name, strings.Join(option.allowed, ", "), option.value)
+		}
+	}
+
+	return nil
+}
+
+func loadRoles(path string) (map[string][]string, error) {
+	if path == "" {
+		return defaultRoles, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roles file: %w", err)
+	}
+
+	var roles map[string][]string
+	err = json.Unmarshal(data, &roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse roles file: %w", err)
+	}
+
+	return roles, nil
+}
+
+func loadPlans(path string) (map[string]PlanLimits, error) {
+	if path == "" {
+		return defaultPlans, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plans file: %w", err)
+	}
+
+	var plans map[string]PlanLimits
+	err = json.Unmarshal(data, &plans)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse plans file: %w", err)
+	}
+
+	for name, limits := range plans {
+		if limits.Requests > 0 && limits.Window <= 0 {
+			return nil, fmt.Errorf("plan %q has requests but no window", name)
+		}
+	}
+
+	return plans, nil
+}
+
+func loadDomains(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disposable domains file: %w", err)
+	}
+
+	return emaildomain.Parse(string(data)), nil
+}
+
+// parsePrefixes parses IPs and CIDR ranges, treating plain IPs as ranges
+// containing only themselves.
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(value); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
@@ -37,6 +37,10 @@ func (d *DB) GetCollection(name string) *mongo.Collection {
 	return d.database.Collection(name)
 }
 
+func (d *DB) Drop(ctx context.Context) error {
+	return d.database.Drop(ctx)
+}
+
 func (d *DB) Disconnect(ctx context.Context) error {
 	return d.client.Disconnect(ctx)
 }
@@ -0,0 +1,42 @@
+// Package dbtest provides throwaway MongoDB databases for repository tests.
+package dbtest
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/BurakYs/go-api-example/database"
+)
+
+// New connects to the server at MONGODB_TEST_URI and returns a database that
+// is dropped when the test ends. The test is skipped when it is not set.
+func New(t *testing.T) *database.DB {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	name := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	db, err := database.NewDB(uri, name)
+	if err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		_ = db.Drop(ctx)
+		_ = db.Disconnect(ctx)
+	})
+
+	return db
+}
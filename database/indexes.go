@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const indexNotFoundCode = 27
+
+// TenantKeys prefixes index keys with tenant_id, which every query on a
+// tenant scoped collection filters by. Unique indexes built from it are
+// unique per tenant.
+func TenantKeys(keys ...bson.E) bson.D {
+	return append(bson.D{{Key: "tenant_id", Value: 1}}, keys...)
+}
+
+// DropIndexes drops the named indexes of collection, ignoring the ones that
+// do not exist. It is meant for removing indexes replaced by a migration.
+func DropIndexes(ctx context.Context, collection *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		err := collection.Indexes().DropOne(ctx, name)
+
+		var cmdErr mongo.CommandError
+		if err != nil && !(errors.As(err, &cmdErr) && cmdErr.HasErrorCode(indexNotFoundCode)) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,17 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"go.uber.org/zap"
 
+	"github.com/BurakYs/go-api-example/app/audit"
+	"github.com/BurakYs/go-api-example/app/invite"
+	"github.com/BurakYs/go-api-example/app/ipaccess"
+	"github.com/BurakYs/go-api-example/app/organization"
+	"github.com/BurakYs/go-api-example/app/ratelimit"
 	"github.com/BurakYs/go-api-example/app/session"
 	"github.com/BurakYs/go-api-example/app/user"
 	"github.com/BurakYs/go-api-example/config"
 	"github.com/BurakYs/go-api-example/database"
 	"github.com/BurakYs/go-api-example/middleware"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/rbac"
+	"github.com/BurakYs/go-api-example/util/rctx"
 )
 
 type Dependencies struct {
@@ -21,16 +31,37 @@ type Dependencies struct {
 	redis  *database.Redis
 	logger *zap.Logger
 
-	userRepository    *user.Repository
-	sessionRepository *session.Repository
-
-	userService    *user.Service
-	sessionService *session.Service
-
-	RateLimiter *middleware.RateLimiter
-	RequireAuth *middleware.RequireAuth
-
-	UserHandler *user.Handler
+	stopWatch context.CancelFunc
+	watchers  sync.WaitGroup
+
+	userRepository     *user.Repository
+	sessionRepository  *session.Repository
+	auditRepository    *audit.Repository
+	orgRepository      *organization.Repository
+	inviteRepository   *invite.Repository
+	ipAccessRepository *ipaccess.Repository
+	policyRepository   *ratelimit.Repository
+
+	userService     *user.Service
+	sessionService  *session.Service
+	auditService    *audit.Service
+	orgService      *organization.Service
+	inviteService   *invite.Service
+	ipAccessService *ipaccess.Service
+	policyService   *ratelimit.Service
+
+	RateLimiter        *middleware.RateLimiter
+	ConcurrencyLimiter *middleware.ConcurrencyLimiter
+	RequireAuth        *middleware.RequireAuth
+	TenantResolver     *middleware.TenantResolver
+
+	UserHandler      *user.Handler
+	AdminUserHandler *user.AdminHandler
+	AuditHandler     *audit.Handler
+	OrgHandler       *organization.Handler
+	InviteHandler    *invite.Handler
+	IPAccessHandler  *ipaccess.Handler
+	PolicyHandler    *ratelimit.Handler
 }
 
 func NewDependencies(cfg *config.Config, db *database.DB, redis *database.Redis, logger *zap.Logger) *Dependencies {
@@ -41,37 +72,179 @@ func NewDependencies(cfg *config.Config, db *database.DB, redis *database.Redis,
 		logger: logger,
 	}
 
+	if d.config.Pagination.CursorSecret != "" {
+		pagination.SetSecret([]byte(d.config.Pagination.CursorSecret))
+	} else {
+		d.logger.Warn("PAGINATION_CURSOR_SECRET is not set, cursors only work on the instance that issued them")
+	}
+
+	roles := rbac.New(d.config.RBAC.Roles)
+
 	d.sessionRepository = session.NewRepository(d.redis)
-	d.sessionService = session.NewService(d.sessionRepository, d.config.Cookie.Expiration)
+	d.sessionService = session.NewService(d.sessionRepository, d.config.Cookie.Expiration, d.config.Impersonation.Expiration, d.config.Tenancy.Default)
+
+	d.auditRepository = audit.NewRepository(d.db)
+	d.auditService = audit.NewService(d.auditRepository)
+	d.AuditHandler = audit.NewHandler(d.auditService)
+
+	d.inviteRepository = invite.NewRepository(d.db)
+	d.inviteService = invite.NewService(d.inviteRepository, d.config.Registration.InviteSecret)
+	d.InviteHandler = invite.NewHandler(d.inviteService)
 
 	d.userRepository = user.NewRepository(d.db)
-	d.userService = user.NewService(d.userRepository)
-	d.UserHandler = user.NewHandler(d.userService, d.sessionService, &d.config.Cookie)
+	d.userService = user.NewService(d.userRepository, &d.config.Password, &d.config.Email)
+	d.UserHandler = user.NewHandler(d.userService, d.sessionService, d.auditService, d.inviteService, &d.config.Cookie, &d.config.Registration)
+	d.AdminUserHandler = user.NewAdminHandler(d.userService, d.sessionService, d.auditService, &d.config.Cookie, &d.config.Impersonation, &d.config.RateLimit, roles)
+
+	d.orgRepository = organization.NewRepository(d.db)
+	d.orgService = organization.NewService(d.orgRepository, d.config.Organization.InvitationExpiration)
+	d.OrgHandler = organization.NewHandler(d.orgService, d.userService)
+
+	d.ipAccessRepository = ipaccess.NewRepository(d.redis)
+	d.ipAccessService = ipaccess.NewService(d.ipAccessRepository, &d.config.IPAccess, d.logger)
+	d.IPAccessHandler = ipaccess.NewHandler(d.ipAccessService)
+
+	d.policyRepository = ratelimit.NewRepository(d.redis)
+	d.policyService = ratelimit.NewService(d.policyRepository, d.logger)
 
 	rateLimiterCfg := middleware.RateLimiterConfig{
 		Enabled:     d.config.RateLimit.Enabled,
 		Window:      d.config.RateLimit.Window,
 		Max:         d.config.RateLimit.Requests,
 		SendHeaders: true,
+		Headers:     middleware.RateLimitHeaders(d.config.RateLimit.Headers),
+		Policies:    d.policyService,
 		KeyFunc: func(c fiber.Ctx) string {
-			return "rate_limit:" + c.IP()
+			if rctx.IsIPAllowed(c) {
+				return ""
+			}
+
+			return rctx.GetClientIP(c)
 		},
+		Backend:       middleware.RateLimiterBackend(d.config.RateLimit.Backend),
+		FailurePolicy: middleware.FailurePolicy(d.config.RateLimit.FailurePolicy),
 	}
 
 	d.RateLimiter = middleware.NewRateLimiter(d.redis, rateLimiterCfg, d.logger)
-	d.RequireAuth = middleware.NewRequireAuth(d.sessionService, d.config.Cookie.Name)
+	d.PolicyHandler = ratelimit.NewHandler(d.policyService, d.RateLimiter)
+	d.ConcurrencyLimiter = middleware.NewConcurrencyLimiter(d.redis, middleware.ConcurrencyLimiterConfig{
+		Enabled:       d.config.RateLimit.Enabled,
+		Max:           d.config.RateLimit.Concurrency,
+		GlobalMax:     d.config.RateLimit.GlobalConcurrency,
+		Lease:         d.config.RateLimit.ConcurrencyLease,
+		KeyFunc:       rateLimiterCfg.KeyFunc,
+		Backend:       rateLimiterCfg.Backend,
+		FailurePolicy: rateLimiterCfg.FailurePolicy,
+	}, d.logger)
+	d.RequireAuth = middleware.NewRequireAuth(d.sessionService, d.config.Cookie.Name, roles, d.loadAccount, d.config.RBAC.UserCacheTTL)
+	d.TenantResolver = middleware.NewTenantResolver(d.sessionService, middleware.TenantResolverConfig{
+		Header:     d.config.Tenancy.Header,
+		BaseDomain: d.config.Tenancy.BaseDomain,
+		Default:    d.config.Tenancy.Default,
+		CookieName: d.config.Cookie.Name,
+	})
 
 	return d
 }
 
+// loadAccount lets RequireAuth see the current state of users without the
+// middleware package depending on the user module.
+func (d *Dependencies) loadAccount(ctx context.Context, userID string) (*middleware.Account, error) {
+	u, err := d.userService.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, middleware.ErrAccountNotFound
+		}
+		return nil, err
+	}
+
+	return &middleware.Account{
+		Roles:    u.Roles,
+		Plan:     u.Plan,
+		Disabled: u.Disabled,
+	}, nil
+}
+
+// recordOffense counts a rate limit rejection towards banning the client IP.
+// It is only meant for limits keyed by IP.
+func (d *Dependencies) recordOffense(c fiber.Ctx) {
+	err := d.ipAccessService.RecordOffense(c, rctx.GetClientIP(c))
+	if err != nil {
+		d.logger.Error("Failed to record rate limit offense", zap.Error(err))
+	}
+}
+
 func (c *Dependencies) Init() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if c.config.Tenancy.Default != "" {
+		err := c.userRepository.BackfillTenant(ctx, c.config.Tenancy.Default)
+		if err != nil {
+			return fmt.Errorf("failed to backfill user tenants: %w", err)
+		}
+
+		err = c.auditRepository.BackfillTenant(ctx, c.config.Tenancy.Default)
+		if err != nil {
+			return fmt.Errorf("failed to backfill audit log tenants: %w", err)
+		}
+
+		err = c.orgRepository.BackfillTenant(ctx, c.config.Tenancy.Default)
+		if err != nil {
+			return fmt.Errorf("failed to backfill organization tenants: %w", err)
+		}
+	}
+
 	err := c.userRepository.CreateIndexes(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to create user indexes: %w", err)
 	}
 
+	err = c.userRepository.BackfillNameLower(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to backfill user names: %w", err)
+	}
+
+	err = c.auditRepository.CreateIndexes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log indexes: %w", err)
+	}
+
+	err = c.orgRepository.CreateIndexes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create organization indexes: %w", err)
+	}
+
+	err = c.orgRepository.BackfillOwners(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to backfill organization owners: %w", err)
+	}
+
+	err = c.inviteRepository.CreateIndexes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create invite indexes: %w", err)
+	}
+
+	err = c.policyService.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load rate limit policies: %w", err)
+	}
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	c.stopWatch = stopWatch
+	c.watchers.Go(func() {
+		c.policyService.Watch(watchCtx)
+	})
+
 	return nil
 }
+
+// Close stops the background work started by Init and waits for it to end.
+// It must be called before the connections it uses are closed.
+func (d *Dependencies) Close() {
+	if d.stopWatch != nil {
+		d.stopWatch()
+	}
+
+	d.watchers.Wait()
+}
@@ -66,7 +66,9 @@ func main() {
 		logger.Fatal("Failed to initialize dependencies", zap.Error(err))
 	}
 
-	server := NewServer(logger)
+	defer deps.Close()
+
+	server := NewServer(logger, &cfg.Proxy)
 	server.SetupRoutes(deps)
 
 	go func(port string) {
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Account is the current state of a session's user. RequireAuth reads it on
+// every request, so changes apply to existing sessions rather than only to
+// the ones created after them.
+type Account struct {
+	Roles    []string
+	Plan     string
+	Disabled bool
+}
+
+// AccountLoader loads the account of userID in the tenant of ctx. It returns
+// ErrAccountNotFound if the user no longer exists.
+type AccountLoader func(ctx context.Context, userID string) (*Account, error)
+
+var ErrAccountNotFound = errors.New("account not found")
+
+// accountCache keeps accounts for a short while so RequireAuth doesn't hit
+// the database on every request. Changes take up to ttl to apply.
+type accountCache struct {
+	load AccountLoader
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]accountCacheEntry
+}
+
+type accountCacheEntry struct {
+	account   *Account
+	expiresAt time.Time
+}
+
+func newAccountCache(load AccountLoader, ttl time.Duration) *accountCache {
+	return &accountCache{
+		load:    load,
+		ttl:     ttl,
+		entries: make(map[string]accountCacheEntry),
+	}
+}
+
+func (c *accountCache) get(ctx context.Context, tenantID, userID string) (*Account, error) {
+	key := tenantID + ":" + userID
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		return entry.account, nil
+	}
+
+	account, err := c.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ttl <= 0 {
+		return account, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Expired entries are dropped here rather than by a background sweep,
+	// which is cheap enough for the number of active users per instance.
+	if len(c.entries) >= accountCacheSweepSize {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+
+	c.entries[key] = accountCacheEntry{account: account, expiresAt: now.Add(c.ttl)}
+	return account, nil
+}
+
+const accountCacheSweepSize = 10_000
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+type ClientIPConfig struct {
+	// Header is X-Forwarded-For, X-Real-IP or Forwarded.
+	Header         string
+	TrustedProxies []netip.Prefix
+}
+
+// ClientIP stores the real client IP in rctx. Proxy headers are only
+// believed when the request comes from a trusted proxy, and forwarding
+// chains are read from the right, stopping at the first address that is not
+// a trusted proxy, since anything left of it may be forged by the client.
+func ClientIP(cfg ClientIPConfig) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		rctx.SetClientIP(c, resolveClientIP(c, cfg))
+		return c.Next()
+	}
+}
+
+func resolveClientIP(c fiber.Ctx, cfg ClientIPConfig) string {
+	remote, ok := netip.AddrFromSlice(c.RequestCtx().RemoteIP())
+	if !ok {
+		return c.IP()
+	}
+	remote = remote.Unmap()
+
+	if !isTrustedProxy(cfg.TrustedProxies, remote) {
+		return remote.String()
+	}
+
+	var hops []string
+	switch strings.ToLower(cfg.Header) {
+	case "x-real-ip":
+		hops = []string{c.Get("X-Real-IP")}
+	case "forwarded":
+		hops = forwardedFor(c.Get(fiber.HeaderForwarded))
+	default:
+		hops = strings.Split(c.Get(fiber.HeaderXForwardedFor), ",")
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+
+		client = addr
+		if !isTrustedProxy(cfg.TrustedProxies, addr) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// forwardedFor returns the for= parameters of an RFC 7239 Forwarded header.
+func forwardedFor(header string) []string {
+	var hops []string
+
+	for element := range strings.SplitSeq(header, ",") {
+		for pair := range strings.SplitSeq(element, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(name, "for") {
+				hops = append(hops, strings.Trim(value, `"`))
+			}
+		}
+	}
+
+	return hops
+}
+
+// parseHop parses an address which may carry a port, with IPv6 addresses in
+// brackets as required by the Forwarded header.
+func parseHop(hop string) (netip.Addr, bool) {
+	hop = strings.TrimSpace(hop)
+
+	addr, err := netip.ParseAddr(strings.Trim(hop, "[]"))
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(hop)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		addr = addrPort.Addr()
+	}
+
+	return addr.Unmap(), true
+}
+
+func isTrustedProxy(proxies []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
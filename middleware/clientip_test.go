@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+func TestClientIP(t *testing.T) {
+	// Requests made by app.Test come from 0.0.0.0.
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/32"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		proxies []netip.Prefix
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "untrusted remote",
+			proxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			want:    "0.0.0.0",
+		},
+		{
+			name:    "single hop",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "spoofed leftmost entry",
+			headers: map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "spoofed leftmost entry behind trusted proxies",
+			headers: map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 10.0.0.2, fd00::1"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "only trusted proxies",
+			headers: map[string]string{"X-Forwarded-For": "10.0.0.1, 10.0.0.2"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "ipv4 mapped ipv6",
+			headers: map[string]string{"X-Forwarded-For": "::ffff:1.2.3.4"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "unknown rightmost entry",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, unknown"},
+			want:    "0.0.0.0",
+		},
+		{
+			name: "empty header",
+			want: "0.0.0.0",
+		},
+		{
+			name:    "real ip",
+			header:  "X-Real-IP",
+			headers: map[string]string{"X-Real-IP": "1.2.3.4", "X-Forwarded-For": "5.6.7.8"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "real ip with port",
+			header:  "X-Real-IP",
+			headers: map[string]string{"X-Real-IP": "1.2.3.4:5678"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "empty real ip",
+			header:  "X-Real-IP",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			want:    "0.0.0.0",
+		},
+		{
+			name:    "forwarded",
+			header:  "Forwarded",
+			headers: map[string]string{"Forwarded": "for=1.2.3.4;proto=https"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "forwarded ipv6 with port",
+			header:  "Forwarded",
+			headers: map[string]string{"Forwarded": `for=192.0.2.60, For="[2001:db8::1]:4711"`},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "forwarded ipv6 without port",
+			header:  "Forwarded",
+			headers: map[string]string{"Forwarded": `for="[2001:db8::1]"`},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "forwarded spoofed leftmost entry",
+			header:  "Forwarded",
+			headers: map[string]string{"Forwarded": "for=6.6.6.6, for=1.2.3.4, for=10.0.0.1"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "forwarded obfuscated identifier",
+			header:  "Forwarded",
+			headers: map[string]string{"Forwarded": "for=1.2.3.4, for=_hidden"},
+			want:    "0.0.0.0",
+		},
+		{
+			name:    "forwarded unknown",
+			header:  "Forwarded",
+			headers: map[string]string{"Forwarded": "for=unknown"},
+			want:    "0.0.0.0",
+		},
+		{
+			name:    "forwarded without for",
+			header:  "Forwarded",
+			headers: map[string]string{"Forwarded": "proto=https;by=10.0.0.1"},
+			want:    "0.0.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies := tt.proxies
+			if proxies == nil {
+				proxies = trusted
+			}
+
+			app := fiber.New()
+			app.Get("/", ClientIP(ClientIPConfig{Header: tt.header, TrustedProxies: proxies}), func(c fiber.Ctx) error {
+				return c.SendString(rctx.GetClientIP(c))
+			})
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+
+			if got := string(body); got != tt.want {
+				t.Fatalf("got client IP %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package middleware
+
+import (
+	"context"
+	_ "embed"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/BurakYs/go-api-example/database"
+	"github.com/BurakYs/go-api-example/httperror"
+)
+
+var (
+	//go:embed concurrencylimiter_acquire.lua
+	acquireSemaphoreScriptContent string
+
+	acquireSemaphoreScript = redis.NewScript(acquireSemaphoreScriptContent)
+)
+
+const (
+	semaphoreAcquired int64 = iota
+	semaphoreClientFull
+	semaphoreGlobalFull
+)
+
+type ConcurrencyLimiterConfig struct {
+	Enabled bool
+
+	// Max caps the in-flight requests of each client, as identified by
+	// KeyFunc, and GlobalMax those of all clients together. Zero disables
+	// either cap.
+	Max       int
+	GlobalMax int
+
+	// Lease is how long a slot is held when it is never released, e.g.
+	// because the instance serving the request crashed. It should be longer
+	// than the slowest request, or slots are freed while still in use.
+	Lease time.Duration
+
+	// KeyFunc identifies the client. Requests for which it returns an empty
+	// string only count towards GlobalMax.
+	KeyFunc func(c fiber.Ctx) string
+
+	// Name namespaces the semaphores of this limit. It defaults to the
+	// method and path of the route.
+	Name string
+
+	Backend       RateLimiterBackend
+	FailurePolicy FailurePolicy
+}
+
+// ConcurrencyLimiter caps the requests being served at once rather than
+// their rate, so slow endpoints can't be saturated by a few clients that
+// stay under their rate limits.
+type ConcurrencyLimiter struct {
+	redis      *database.Redis
+	memory     *memorySemaphore
+	defaultCfg ConcurrencyLimiterConfig
+	logger     *zap.Logger
+
+	redisDown atomic.Bool
+}
+
+type ConcurrencyLimiterBuilder struct {
+	limiter *ConcurrencyLimiter
+	config  ConcurrencyLimiterConfig
+}
+
+func NewConcurrencyLimiter(redis *database.Redis, defaultCfg ConcurrencyLimiterConfig, logger *zap.Logger) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		redis:      redis,
+		memory:     newMemorySemaphore(),
+		defaultCfg: defaultCfg,
+		logger:     logger,
+	}
+}
+
+func (cl *ConcurrencyLimiter) Builder() *ConcurrencyLimiterBuilder {
+	return &ConcurrencyLimiterBuilder{
+		limiter: cl,
+		config:  cl.defaultCfg,
+	}
+}
+
+func (cl *ConcurrencyLimiter) Middleware() fiber.Handler {
+	return cl.Builder().Middleware()
+}
+
+func (b *ConcurrencyLimiterBuilder) WithEnabled(enabled bool) *ConcurrencyLimiterBuilder {
+	b.config.Enabled = enabled
+	return b
+}
+
+func (b *ConcurrencyLimiterBuilder) WithMax(maxRequests int) *ConcurrencyLimiterBuilder {
+	b.config.Max = maxRequests
+	return b
+}
+
+func (b *ConcurrencyLimiterBuilder) WithGlobalMax(maxRequests int) *ConcurrencyLimiterBuilder {
+	b.config.GlobalMax = maxRequests
+	return b
+}
+
+func (b *ConcurrencyLimiterBuilder) WithLease(lease time.Duration) *ConcurrencyLimiterBuilder {
+	b.config.Lease = lease
+	return b
+}
+
+func (b *ConcurrencyLimiterBuilder) WithName(name string) *ConcurrencyLimiterBuilder {
+	b.config.Name = name
+	return b
+}
+
+func (b *ConcurrencyLimiterBuilder) WithKeyFunc(keyFunc func(c fiber.Ctx) string) *ConcurrencyLimiterBuilder {
+	b.config.KeyFunc = keyFunc
+	return b
+}
+
+func (b *ConcurrencyLimiterBuilder) WithBackend(backend RateLimiterBackend) *ConcurrencyLimiterBuilder {
+	b.config.Backend = backend
+	return b
+}
+
+func (b *ConcurrencyLimiterBuilder) WithFailurePolicy(policy FailurePolicy) *ConcurrencyLimiterBuilder {
+	b.config.FailurePolicy = policy
+	return b
+}
+
+func (b *ConcurrencyLimiterBuilder) Middleware() fiber.Handler {
+	if b.config.Lease < time.Millisecond {
+		b.limiter.logger.Warn("ConcurrencyLimiter lease is less than 1 millisecond, setting to 1 minute")
+		b.config.Lease = time.Minute
+	}
+
+	return func(c fiber.Ctx) error {
+		if !b.config.Enabled || (b.config.Max <= 0 && b.config.GlobalMax <= 0) {
+			return c.Next()
+		}
+
+		name := b.config.Name
+		policy := name
+		if name == "" {
+			name = c.Method() + ":" + c.Route().Path
+			policy = "concurrency"
+		}
+
+		maxRequests := b.config.Max
+		id := b.config.KeyFunc(c)
+		if id == "" {
+			maxRequests = 0
+		}
+
+		key := "concurrency:" + name + ":" + id
+		globalKey := "concurrency:" + name
+		leaseID := uuid.NewString()
+
+		status, release, err := b.acquire(c, key, globalKey, maxRequests, leaseID)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case semaphoreClientFull:
+			return httperror.New(fiber.StatusTooManyRequests, "Too many concurrent requests").
+				WithExtra("policy", policy)
+		case semaphoreGlobalFull:
+			c.Set(fiber.HeaderRetryAfter, "1")
+			return httperror.New(fiber.StatusServiceUnavailable, "Service is busy, please try again later")
+		}
+
+		if release != nil {
+			defer release()
+		}
+
+		return c.Next()
+	}
+}
+
+// acquire takes a slot from the semaphores and returns a function giving it
+// back, or a nil one when the request isn't limited.
+func (b *ConcurrencyLimiterBuilder) acquire(c fiber.Ctx, key, globalKey string, maxRequests int, leaseID string) (int64, func(), error) {
+	if b.config.Backend == BackendMemory {
+		return b.acquireMemory(key, globalKey, maxRequests)
+	}
+
+	now := time.Now().UTC().UnixMilli()
+	status, err := acquireSemaphoreScript.Run(c, b.limiter.redis.Client(), []string{key, globalKey},
+		maxRequests, b.config.GlobalMax, b.config.Lease.Milliseconds(), now, leaseID,
+	).Int64()
+	if err != nil {
+		if !b.limiter.redisDown.Swap(true) {
+			b.limiter.logger.Error("ConcurrencyLimiter Redis backend failed, applying failure policy",
+				zap.String("policy", string(b.config.FailurePolicy)),
+				zap.Error(err),
+			)
+		}
+
+		switch b.config.FailurePolicy {
+		case FailOpen:
+			return semaphoreAcquired, nil, nil
+		case FailClosed:
+			return 0, nil, httperror.New(fiber.StatusServiceUnavailable, "Service is temporarily unavailable")
+		default:
+			return b.acquireMemory(key, globalKey, maxRequests)
+		}
+	}
+
+	if b.limiter.redisDown.Swap(false) {
+		b.limiter.logger.Info("ConcurrencyLimiter Redis backend recovered")
+	}
+
+	if status != semaphoreAcquired {
+		return status, nil, nil
+	}
+
+	return status, func() {
+		// The request context may already be cancelled, but the slot has to
+		// be released regardless. If this fails the lease frees it later.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c), 5*time.Second)
+		defer cancel()
+
+		pipe := b.limiter.redis.Client().Pipeline()
+		pipe.ZRem(ctx, key, leaseID)
+		pipe.ZRem(ctx, globalKey, leaseID)
+
+		_, err := pipe.Exec(ctx)
+		if err != nil {
+			b.limiter.logger.Warn("ConcurrencyLimiter failed to release slot", zap.Error(err))
+		}
+	}, nil
+}
+
+func (b *ConcurrencyLimiterBuilder) acquireMemory(key, globalKey string, maxRequests int) (int64, func(), error) {
+	status := b.limiter.memory.acquire(key, globalKey, maxRequests, b.config.GlobalMax)
+	if status != semaphoreAcquired {
+		return status, nil, nil
+	}
+
+	return status, func() {
+		b.limiter.memory.release(key, globalKey, maxRequests, b.config.GlobalMax)
+	}, nil
+}
+
+// memorySemaphore counts in-flight requests in process. Unlike in Redis no
+// leases are needed, since a crash loses the counts along with the requests.
+type memorySemaphore struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func newMemorySemaphore() *memorySemaphore {
+	return &memorySemaphore{
+		inFlight: make(map[string]int),
+	}
+}
+
+func (s *memorySemaphore) acquire(key, globalKey string, maxRequests, globalMax int) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if maxRequests > 0 && s.inFlight[key] >= maxRequests {
+		return semaphoreClientFull
+	}
+
+	if globalMax > 0 && s.inFlight[globalKey] >= globalMax {
+		return semaphoreGlobalFull
+	}
+
+	if maxRequests > 0 {
+		s.inFlight[key]++
+	}
+
+	if globalMax > 0 {
+		s.inFlight[globalKey]++
+	}
+
+	return semaphoreAcquired
+}
+
+func (s *memorySemaphore) release(key, globalKey string, maxRequests, globalMax int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if maxRequests > 0 {
+		s.decrement(key)
+	}
+
+	if globalMax > 0 {
+		s.decrement(globalKey)
+	}
+}
+
+func (s *memorySemaphore) decrement(key string) {
+	s.inFlight[key]--
+	if s.inFlight[key] <= 0 {
+		delete(s.inFlight, key)
+	}
+}
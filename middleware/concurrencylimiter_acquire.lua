@@ -0,0 +1,36 @@
+-- Semaphores are sorted sets of lease IDs scored by when they expire, so
+-- slots held by crashed instances free themselves. KEYS[1] holds the
+-- client's leases and KEYS[2] everyone's. A max of 0 disables a semaphore.
+-- Returns 0 when acquired, 1 when the client is at its limit and 2 when
+-- the global limit is reached.
+local max = tonumber(ARGV[1])
+local globalMax = tonumber(ARGV[2])
+local lease = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local leaseID = ARGV[5]
+
+if max > 0 then
+  redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+  if redis.call("ZCARD", KEYS[1]) >= max then
+    return 1
+  end
+end
+
+if globalMax > 0 then
+  redis.call("ZREMRANGEBYSCORE", KEYS[2], "-inf", now)
+  if redis.call("ZCARD", KEYS[2]) >= globalMax then
+    return 2
+  end
+end
+
+if max > 0 then
+  redis.call("ZADD", KEYS[1], now + lease, leaseID)
+  redis.call("PEXPIRE", KEYS[1], lease)
+end
+
+if globalMax > 0 then
+  redis.call("ZADD", KEYS[2], now + lease, leaseID)
+  redis.call("PEXPIRE", KEYS[2], lease)
+end
+
+return 0
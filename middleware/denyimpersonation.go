@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/httperror"
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+// DenyImpersonation rejects requests made through an impersonation session.
+// It must run after RequireAuth.
+func DenyImpersonation() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if rctx.GetImpersonatorID(c) != "" {
+			return httperror.New(fiber.StatusForbidden, "This action is not allowed while impersonating")
+		}
+
+		return c.Next()
+	}
+}
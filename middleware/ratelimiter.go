@@ -2,11 +2,14 @@ package middleware
 
 import (
 	_ "embed"
-	"strconv"
+	"maps"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BurakYs/go-api-example/database"
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
@@ -20,8 +23,53 @@ var (
 	//go:embed ratelimiter_fixed.lua
 	fixedLimiterScriptContent string
 
-	slidingLimiterScript = redis.NewScript(slidingLimiterScriptContent)
-	fixedLimiterScript   = redis.NewScript(fixedLimiterScriptContent)
+	//go:embed ratelimiter_slidingcounter.lua
+	slidingCounterLimiterScriptContent string
+
+	//go:embed ratelimiter_tokenbucket.lua
+	tokenBucketLimiterScriptContent string
+
+	//go:embed ratelimiter_gcra.lua
+	gcraLimiterScriptContent string
+
+	limiterScripts = map[limiterAlgorithm]*redis.Script{
+		algorithmFixed:          redis.NewScript(fixedLimiterScriptContent),
+		algorithmSliding:        redis.NewScript(slidingLimiterScriptContent),
+		algorithmSlidingCounter: redis.NewScript(slidingCounterLimiterScriptContent),
+		algorithmTokenBucket:    redis.NewScript(tokenBucketLimiterScriptContent),
+		algorithmGCRA:           redis.NewScript(gcraLimiterScriptContent),
+	}
+)
+
+type limiterAlgorithm int
+
+const (
+	algorithmFixed limiterAlgorithm = iota
+	algorithmSliding
+	algorithmSlidingCounter
+	algorithmTokenBucket
+	algorithmGCRA
+)
+
+type RateLimiterBackend string
+
+const (
+	BackendRedis RateLimiterBackend = "redis"
+
+	// BackendMemory keeps counters in process. Limits then apply per
+	// instance, so it only suits single node deployments.
+	BackendMemory RateLimiterBackend = "memory"
+)
+
+// FailurePolicy decides what happens to requests while the Redis backend
+// is unavailable.
+type FailurePolicy string
+
+const (
+	// FailFallback limits requests in process until Redis is back.
+	FailFallback FailurePolicy = "fallback"
+	FailOpen     FailurePolicy = "open"
+	FailClosed   FailurePolicy = "closed"
 )
 
 type RateLimiterConfig struct {
@@ -29,42 +77,143 @@ type RateLimiterConfig struct {
 	Window      time.Duration
 	Max         int
 	SendHeaders bool
-	KeyFunc     func(c fiber.Ctx) string
+	Headers     RateLimitHeaders
+
+	// KeyFunc identifies the client being limited, e.g. by IP. Requests for
+	// which it returns an empty string are not limited.
+	KeyFunc func(c fiber.Ctx) string
+
+	// Name namespaces the keys of this limit and names it to clients. Keys
+	// default to the method and path of the route, which is never shown.
+	Name string
+
+	// Burst is the number of requests the token bucket and GCRA limiters
+	// allow back to back, while Max per Window is their sustained rate. It
+	// defaults to Max.
+	Burst int
+
+	// Cost is how many requests each request counts as, so expensive routes
+	// use up a limit faster. It defaults to 1.
+	Cost int
+
+	// Policy names a limit in Policies that replaces Max, Window and Burst
+	// while it exists, so it can be changed without a restart.
+	Policy   string
+	Policies PolicySource
+
+	// LimitFunc resolves the limit per request, e.g. from the plan of the
+	// authenticated user, and wins over Policy. It may return false to keep
+	// the limit otherwise in effect.
+	LimitFunc func(c fiber.Ctx) (Limit, bool)
+
+	// OnReject is called for every request this limit rejects, e.g. to ban
+	// clients that keep hitting it.
+	OnReject func(c fiber.Ctx)
+
+	Backend       RateLimiterBackend
+	FailurePolicy FailurePolicy
+}
+
+// Limit is the limit applied to a single request. A Max of zero or less
+// lifts the limit, e.g. for plans without one.
+type Limit struct {
+	Max    int
+	Window time.Duration
+	Burst  int
+}
+
+// PolicySource looks up named limits that may change at runtime.
+type PolicySource interface {
+	Policy(name string) (Limit, bool)
 }
 
 type RateLimiter struct {
 	redis      *database.Redis
+	memory     *memoryStore
 	defaultCfg RateLimiterConfig
 	logger     *zap.Logger
+
+	// redisDown is set while Redis calls fail, so the outage and the
+	// recovery are logged once rather than on every request.
+	redisDown atomic.Bool
+
+	// policies holds the limits routes referring to a policy were
+	// configured with, by policy name.
+	policiesMu sync.RWMutex
+	policies   map[string]Limit
 }
 
 type RateLimiterBuilder struct {
 	rateLimiter *RateLimiter
 	config      RateLimiterConfig
-	sliding     bool
+	algorithm   limiterAlgorithm
+	period      QuotaPeriod
 }
 
 func NewRateLimiter(redis *database.Redis, defaultCfg RateLimiterConfig, logger *zap.Logger) *RateLimiter {
 	return &RateLimiter{
 		redis:      redis,
+		memory:     newMemoryStore(),
 		defaultCfg: defaultCfg,
 		logger:     logger,
+		policies:   make(map[string]Limit),
 	}
 }
 
+// Policies returns the names of the policies routes refer to, along with the
+// limits those routes apply while the policy isn't set.
+func (rl *RateLimiter) Policies() map[string]Limit {
+	rl.policiesMu.RLock()
+	defer rl.policiesMu.RUnlock()
+
+	return maps.Clone(rl.policies)
+}
+
 func (rl *RateLimiter) Fixed() *RateLimiterBuilder {
 	return &RateLimiterBuilder{
 		rateLimiter: rl,
 		config:      rl.defaultCfg,
-		sliding:     false,
+		algorithm:   algorithmFixed,
 	}
 }
 
+// Sliding keeps a log of every request in the window. It is exact, but
+// stores up to Max entries per key.
 func (rl *RateLimiter) Sliding() *RateLimiterBuilder {
 	return &RateLimiterBuilder{
 		rateLimiter: rl,
 		config:      rl.defaultCfg,
-		sliding:     true,
+		algorithm:   algorithmSliding,
+	}
+}
+
+// SlidingCounter approximates Sliding from the counts of two fixed windows,
+// using constant memory per key regardless of Max.
+func (rl *RateLimiter) SlidingCounter() *RateLimiterBuilder {
+	return &RateLimiterBuilder{
+		rateLimiter: rl,
+		config:      rl.defaultCfg,
+		algorithm:   algorithmSlidingCounter,
+	}
+}
+
+// TokenBucket allows bursts of up to Burst requests, refilling Max requests
+// per Window.
+func (rl *RateLimiter) TokenBucket() *RateLimiterBuilder {
+	return &RateLimiterBuilder{
+		rateLimiter: rl,
+		config:      rl.defaultCfg,
+		algorithm:   algorithmTokenBucket,
+	}
+}
+
+// GCRA behaves like TokenBucket but spaces requests evenly and stores a
+// single timestamp per key.
+func (rl *RateLimiter) GCRA() *RateLimiterBuilder {
+	return &RateLimiterBuilder{
+		rateLimiter: rl,
+		config:      rl.defaultCfg,
+		algorithm:   algorithmGCRA,
 	}
 }
 
@@ -87,70 +236,348 @@ func (b *RateLimiterBuilder) WithMax(maxRequests int) *RateLimiterBuilder {
 	return b
 }
 
+func (b *RateLimiterBuilder) WithName(name string) *RateLimiterBuilder {
+	b.config.Name = name
+	return b
+}
+
+func (b *RateLimiterBuilder) WithBurst(burst int) *RateLimiterBuilder {
+	b.config.Burst = burst
+	return b
+}
+
+func (b *RateLimiterBuilder) WithCost(cost int) *RateLimiterBuilder {
+	b.config.Cost = cost
+	return b
+}
+
+func (b *RateLimiterBuilder) WithPolicy(name string) *RateLimiterBuilder {
+	b.config.Policy = name
+	return b
+}
+
+func (b *RateLimiterBuilder) WithLimitFunc(limitFunc func(c fiber.Ctx) (Limit, bool)) *RateLimiterBuilder {
+	b.config.LimitFunc = limitFunc
+	return b
+}
+
+func (b *RateLimiterBuilder) WithOnReject(onReject func(c fiber.Ctx)) *RateLimiterBuilder {
+	b.config.OnReject = onReject
+	return b
+}
+
+func (b *RateLimiterBuilder) WithBackend(backend RateLimiterBackend) *RateLimiterBuilder {
+	b.config.Backend = backend
+	return b
+}
+
+func (b *RateLimiterBuilder) WithFailurePolicy(policy FailurePolicy) *RateLimiterBuilder {
+	b.config.FailurePolicy = policy
+	return b
+}
+
 func (b *RateLimiterBuilder) WithSendHeaders(sendHeaders bool) *RateLimiterBuilder {
 	b.config.SendHeaders = sendHeaders
 	return b
 }
 
+func (b *RateLimiterBuilder) WithHeaders(headers RateLimitHeaders) *RateLimiterBuilder {
+	b.config.Headers = headers
+	return b
+}
+
 func (b *RateLimiterBuilder) WithKeyFunc(keyFunc func(c fiber.Ctx) string) *RateLimiterBuilder {
 	b.config.KeyFunc = keyFunc
 	return b
 }
 
 func (b *RateLimiterBuilder) Middleware() fiber.Handler {
-	var script *redis.Script
-	if b.sliding {
-		script = slidingLimiterScript
-	} else {
-		script = fixedLimiterScript
-	}
+	return b.rateLimiter.Stack(b)
+}
 
-	if b.config.Window < time.Second {
-		b.rateLimiter.logger.Warn("RateLimiter window is less than 1 second, setting to 1 second")
-		b.config.Window = time.Second
-	}
+// Stack applies several limits to a route, e.g. one per IP and one per
+// account. Every limit counts the request, it is rejected if any of them is
+// exceeded, and the headers describe the most restrictive one. Quotas are
+// only checked once the other limits allow the request, so requests
+// rejected by a short window don't use up the client's quota.
+func (rl *RateLimiter) Stack(builders ...*RateLimiterBuilder) fiber.Handler {
+	var limits, quotas []*RateLimiterBuilder
+	for _, b := range builders {
+		b.prepare()
 
-	windowSecs := int(b.config.Window.Seconds())
+		if b.period != "" {
+			quotas = append(quotas, b)
+		} else {
+			limits = append(limits, b)
+		}
+	}
 
 	return func(c fiber.Ctx) error {
-		if !b.config.Enabled {
+		var results []*limitResult
+		var tightest *limitResult
+
+		for _, group := range [][]*RateLimiterBuilder{limits, quotas} {
+			if tightest != nil && !tightest.allowed {
+				break
+			}
+
+			for _, b := range group {
+				result, err := b.check(c)
+				if err != nil {
+					return err
+				}
+
+				if result == nil {
+					continue
+				}
+
+				results = append(results, result)
+				if result.tighterThan(tightest) {
+					tightest = result
+				}
+			}
+		}
+
+		if tightest == nil {
 			return c.Next()
 		}
 
-		key := []string{b.config.KeyFunc(c)}
-		now := time.Now().UTC().UnixMilli()
+		setRateLimitHeaders(c, results, tightest)
 
-		result, err := script.Run(c, b.rateLimiter.redis.Client(), key, b.config.Max, windowSecs, now).Int64Slice()
-		if err != nil {
-			return err
+		if !tightest.allowed {
+			for _, result := range results {
+				if !result.allowed && result.onReject != nil {
+					result.onReject(c)
+				}
+			}
+
+			return httperror.New(fiber.StatusTooManyRequests, "Too many requests").
+				WithExtra("retryAfter", tightest.resetSeconds()).
+				WithExtra("policy", tightest.policy)
 		}
 
-		remaining := result[0]
-		resetSeconds := result[1]
-		allowed := remaining >= 0
+		return c.Next()
+	}
+}
+
+type limitResult struct {
+	policy    string
+	limit     int
+	window    time.Duration
+	remaining int64
+	resetMs   int64
+	allowed   bool
+
+	sendHeaders bool
+	headers     RateLimitHeaders
+	onReject    func(c fiber.Ctx)
+}
+
+// resetSeconds converts the milliseconds returned by the limiter to the
+// whole seconds used in headers, rounding up so clients never retry early.
+func (r *limitResult) resetSeconds() int64 {
+	return (max(r.resetMs, 0) + 999) / 1000
+}
+
+// tighterThan reports whether r restricts the client more than other: a
+// rejection beats an allowed request, and otherwise the longer wait or the
+// fewer remaining requests wins.
+func (r *limitResult) tighterThan(other *limitResult) bool {
+	if other == nil {
+		return true
+	}
 
-		if remaining < 0 {
-			remaining = 0
+	if r.allowed != other.allowed {
+		return !r.allowed
+	}
+
+	if !r.allowed {
+		return r.resetMs > other.resetMs
+	}
+
+	return r.remaining < other.remaining
+}
+
+func (b *RateLimiterBuilder) prepare() {
+	if b.config.Window < time.Millisecond {
+		b.rateLimiter.logger.Warn("RateLimiter window is less than 1 millisecond, setting to 1 millisecond")
+		b.config.Window = time.Millisecond
+	}
+
+	if b.config.Cost <= 0 {
+		b.config.Cost = 1
+	}
+
+	if b.config.Policy != "" {
+		b.rateLimiter.policiesMu.Lock()
+		b.rateLimiter.policies[b.config.Policy] = Limit{
+			Max:    b.config.Max,
+			Window: b.config.Window,
+			Burst:  b.config.Burst,
 		}
+		b.rateLimiter.policiesMu.Unlock()
+	}
+}
+
+// check counts the request against this limit. It returns a nil result when
+// the limit does not apply: it is disabled, the key function returned an
+// empty key, its Max is not positive, or Redis failed under the fail-open
+// policy. Algorithms are never run with a Max of zero or less.
+func (b *RateLimiterBuilder) check(c fiber.Ctx) (*limitResult, error) {
+	if !b.config.Enabled {
+		return nil, nil
+	}
+
+	id := b.config.KeyFunc(c)
+	if id == "" {
+		return nil, nil
+	}
+
+	limit := b.limit(c)
+	if limit.Max <= 0 {
+		return nil, nil
+	}
 
-		if resetSeconds < 0 {
-			resetSeconds = 0
+	now := time.Now().UTC()
+	key := "rate_limit:" + b.namespace(c) + ":" + id
+	windowMs := limit.Window.Milliseconds()
+
+	// Quotas count calendar periods rather than windows starting at the
+	// first request, so their counters expire when the period ends.
+	if b.period != "" {
+		start, end := b.period.bounds(now)
+		key += ":" + start.Format("20060102")
+		windowMs = max(end.Sub(now).Milliseconds(), 1)
+		limit.Window = end.Sub(start)
+	}
+
+	remaining, resetMs, err := b.take(c, key, limit, windowMs, now.UnixMilli())
+	if err != nil {
+		switch b.config.FailurePolicy {
+		case FailOpen:
+			return nil, nil
+		case FailClosed:
+			return nil, httperror.New(fiber.StatusServiceUnavailable, "Service is temporarily unavailable")
+		default:
+			remaining, resetMs = b.rateLimiter.memory.take(b.algorithm, key, int64(limit.Max), windowMs, int64(limit.Burst), int64(b.config.Cost), now.UnixMilli())
 		}
+	}
 
-		if b.config.SendHeaders {
-			if !allowed {
-				c.Set("Retry-After", strconv.FormatInt(resetSeconds, 10))
-			} else {
-				c.Set("X-RateLimit-Limit", strconv.Itoa(b.config.Max))
-				c.Set("X-RateLimit-Remaining", strconv.Itoa(int(remaining)))
-				c.Set("X-RateLimit-Reset", strconv.FormatInt(resetSeconds, 10))
-			}
+	return &limitResult{
+		policy:    b.policyName(),
+		limit:     limit.Max,
+		window:    limit.Window,
+		remaining: max(remaining, 0),
+		resetMs:   resetMs,
+		allowed:   remaining >= 0,
+
+		sendHeaders: b.config.SendHeaders,
+		headers:     b.config.Headers,
+		onReject:    b.config.OnReject,
+	}, nil
+}
+
+// limit resolves the limit of the request, letting the policy and then
+// LimitFunc override the configured one.
+func (b *RateLimiterBuilder) limit(c fiber.Ctx) Limit {
+	limit := Limit{
+		Max:    b.config.Max,
+		Window: b.config.Window,
+		Burst:  b.config.Burst,
+	}
+
+	if b.config.Policy != "" && b.config.Policies != nil {
+		if policy, ok := b.config.Policies.Policy(b.config.Policy); ok {
+			limit = policy
 		}
+	}
 
-		if !allowed {
-			return httperror.New(fiber.StatusTooManyRequests, "Too many requests")
+	if b.config.LimitFunc != nil {
+		if resolved, ok := b.config.LimitFunc(c); ok {
+			limit = resolved
 		}
+	}
 
-		return c.Next()
+	limit.Window = max(limit.Window, time.Millisecond)
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Max
+	}
+
+	return limit
+}
+
+// namespace separates the counters of different limits on the same key, so
+// login and register attempts from one IP don't share a bucket. It defaults
+// to the route the limiter is mounted on.
+func (b *RateLimiterBuilder) namespace(c fiber.Ctx) string {
+	if b.config.Name != "" {
+		return b.config.Name
+	}
+
+	return c.Method() + ":" + c.Route().Path
+}
+
+// policyName is the name clients see for this limit in RateLimit-Policy and
+// rejections: the policy it refers to, else its name.
+func (b *RateLimiterBuilder) policyName() string {
+	if b.config.Policy != "" {
+		return b.config.Policy
+	}
+
+	if b.config.Name != "" {
+		return b.config.Name
+	}
+
+	return "default"
+}
+
+// take counts the request against the configured backend. It only returns
+// an error if Redis fails, leaving the failure policy to the caller.
+func (b *RateLimiterBuilder) take(c fiber.Ctx, key string, limit Limit, windowMs, now int64) (int64, int64, error) {
+	if b.config.Backend == BackendMemory {
+		remaining, resetMs := b.rateLimiter.memory.take(b.algorithm, key, int64(limit.Max), windowMs, int64(limit.Burst), int64(b.config.Cost), now)
+		return remaining, resetMs, nil
+	}
+
+	script := limiterScripts[b.algorithm]
+	result, err := script.Run(c, b.rateLimiter.redis.Client(), []string{key}, limit.Max, windowMs, now, limit.Burst, uuid.NewString(), b.config.Cost).Int64Slice()
+	if err != nil {
+		b.redisFailed(err)
+		return 0, 0, err
+	}
+
+	b.redisSucceeded()
+	return result[0], result[1], nil
+}
+
+// redisFailed and redisSucceeded log when the Redis backend goes down and
+// when it recovers, once per transition.
+func (b *RateLimiterBuilder) redisFailed(err error) {
+	if !b.rateLimiter.redisDown.Swap(true) {
+		b.rateLimiter.logger.Error("RateLimiter Redis backend failed, applying failure policy",
+			zap.String("policy", string(b.config.FailurePolicy)),
+			zap.Error(err),
+		)
+	}
+}
+
+func (b *RateLimiterBuilder) redisSucceeded() {
+	if b.rateLimiter.redisDown.Swap(false) {
+		b.rateLimiter.logger.Info("RateLimiter Redis backend recovered")
+	}
+}
+
+// KeyFromBody derives rate limit keys from the validated request body, e.g.
+// the email on a login request, so attempts against one account are limited
+// no matter how many IPs they come from. Invalid bodies are not limited here
+// since the handler rejects them anyway.
+func KeyFromBody[T any](keyFunc func(c fiber.Ctx, body *T) string) func(c fiber.Ctx) string {
+	return func(c fiber.Ctx) string {
+		body, err := ValidateBody[T](c)
+		if err != nil {
+			return ""
+		}
+
+		return keyFunc(c, body)
 	}
 }
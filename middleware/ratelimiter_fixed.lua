@@ -2,12 +2,13 @@ local key = KEYS[1]
 local max = tonumber(ARGV[1])
 local window = tonumber(ARGV[2])
 local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[6])
 
 local current = tonumber(redis.call("GET", key) or "0")
-if current >= max then
-  local ttl = redis.call("TTL", key)
+if current + cost > max then
+  local ttl = redis.call("PTTL", key)
   if ttl < 0 then
-    redis.call("EXPIRE", key, window)
+    redis.call("PEXPIRE", key, window)
     ttl = window
   end
 
@@ -15,12 +16,12 @@ if current >= max then
   return {-1, ttl}
 end
 
-current = redis.call("INCR", key)
-if current == 1 then
-  redis.call("EXPIRE", key, window)
+current = redis.call("INCRBY", key, cost)
+if current == cost then
+  redis.call("PEXPIRE", key, window)
 end
 
-local ttl = redis.call("TTL", key)
+local ttl = redis.call("PTTL", key)
 if ttl < 0 then ttl = 0 end
 local remaining = max - current
-return {remaining, ttl}
\ No newline at end of file
+return {remaining, ttl}
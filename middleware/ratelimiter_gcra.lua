@@ -0,0 +1,30 @@
+-- Generic cell rate algorithm: requests are spaced window / max apart, with
+-- up to burst of them allowed back to back. Only the theoretical arrival
+-- time of the next request is stored. A request costing n counts as n
+-- requests arriving at once.
+local key = KEYS[1]
+local max = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local burst = tonumber(ARGV[4])
+local cost = tonumber(ARGV[6])
+
+local interval = window / max
+local tolerance = interval * burst
+
+local tat = tonumber(redis.call("GET", key) or now)
+if tat < now then
+  tat = now
+end
+
+local newTat = tat + interval * cost
+local allowAt = newTat - tolerance
+if allowAt > now then
+  return {-1, math.ceil(allowAt - now)}
+end
+
+local resetIn = math.ceil(newTat - now)
+redis.call("SET", key, newTat, "PX", resetIn)
+
+local remaining = math.floor((tolerance - (newTat - now)) / interval)
+return {math.max(remaining, 0), resetIn}
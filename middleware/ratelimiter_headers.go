@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RateLimitHeaders selects the header fields describing rate limits.
+type RateLimitHeaders string
+
+const (
+	// HeadersLegacy sends X-RateLimit-Limit, X-RateLimit-Remaining and
+	// X-RateLimit-Reset for the most restrictive limit.
+	HeadersLegacy RateLimitHeaders = "legacy"
+
+	// HeadersIETF sends the RateLimit and RateLimit-Policy fields of the
+	// IETF RateLimit header fields draft, listing every limit.
+	HeadersIETF RateLimitHeaders = "ietf"
+
+	HeadersBoth RateLimitHeaders = "both"
+)
+
+// setRateLimitHeaders describes the limits applied to the request, whether
+// it was allowed or not, plus Retry-After when it was rejected.
+func setRateLimitHeaders(c fiber.Ctx, results []*limitResult, tightest *limitResult) {
+	if !tightest.sendHeaders {
+		return
+	}
+
+	if !tightest.allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(tightest.resetSeconds(), 10))
+	}
+
+	headers := tightest.headers
+	if headers != HeadersIETF {
+		c.Set("X-RateLimit-Limit", strconv.Itoa(tightest.limit))
+		c.Set("X-RateLimit-Remaining", strconv.FormatInt(tightest.remaining, 10))
+		c.Set("X-RateLimit-Reset", strconv.FormatInt(tightest.resetSeconds(), 10))
+	}
+
+	if headers == HeadersIETF || headers == HeadersBoth {
+		policies := make([]string, 0, len(results))
+		limits := make([]string, 0, len(results))
+
+		for _, result := range results {
+			name := quoteSFString(result.policy)
+			window := max(int64(result.window.Seconds()), 1)
+
+			policies = append(policies, name+";q="+strconv.Itoa(result.limit)+";w="+strconv.FormatInt(window, 10))
+			limits = append(limits, name+";r="+strconv.FormatInt(result.remaining, 10)+";t="+strconv.FormatInt(result.resetSeconds(), 10))
+		}
+
+		c.Set("RateLimit-Policy", strings.Join(policies, ", "))
+		c.Set("RateLimit", strings.Join(limits, ", "))
+	}
+}
+
+// quoteSFString encodes s as a structured field string (RFC 8941).
+func quoteSFString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+)
+
+const (
+	memoryStoreShards = 64
+
+	// Expired entries are swept from a shard at most this often, in
+	// milliseconds. Until then they are only replaced when reused.
+	memoryStoreSweepInterval = 60_000
+)
+
+// memoryStore implements every rate limiting algorithm in process, for
+// single node deployments and as a fallback while Redis is unavailable.
+// Keys are spread over shards to keep lock contention low.
+type memoryStore struct {
+	seed   maphash.Seed
+	shards [memoryStoreShards]memoryShard
+}
+
+type memoryShard struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	nextSweep int64
+}
+
+// memoryEntry holds the state of one key. Each algorithm only uses the
+// fields it needs, mirroring what its Lua script stores in Redis.
+type memoryEntry struct {
+	expiresAt int64
+
+	count     int64
+	previous  int64
+	window    int64
+	updatedAt int64
+	value     float64
+	log       []int64
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{
+		seed: maphash.MakeSeed(),
+	}
+
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*memoryEntry)
+	}
+
+	return s
+}
+
+// take counts a request costing cost for key and returns the same values as
+// the Lua scripts: the remaining requests, or -1 if rejected, and the
+// milliseconds until the limit resets or the request can be retried.
+func (s *memoryStore) take(alg limiterAlgorithm, key string, maxRequests, window, burst, cost, now int64) (int64, int64) {
+	shard := &s.shards[maphash.String(s.seed, key)%memoryStoreShards]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if now >= shard.nextSweep {
+		shard.sweep(now)
+	}
+
+	entry, ok := shard.entries[key]
+	if !ok || entry.expiresAt <= now {
+		entry = &memoryEntry{}
+		shard.entries[key] = entry
+	}
+
+	switch alg {
+	case algorithmSliding:
+		return entry.takeSliding(maxRequests, window, cost, now)
+	case algorithmSlidingCounter:
+		return entry.takeSlidingCounter(maxRequests, window, cost, now)
+	case algorithmTokenBucket:
+		return entry.takeTokenBucket(maxRequests, window, burst, cost, now)
+	case algorithmGCRA:
+		return entry.takeGCRA(maxRequests, window, burst, cost, now)
+	default:
+		return entry.takeFixed(maxRequests, window, cost, now)
+	}
+}
+
+// count returns the requests counted for key by the fixed window algorithm
+// without counting a new one.
+func (s *memoryStore) count(key string, now int64) int64 {
+	shard := &s.shards[maphash.String(s.seed, key)%memoryStoreShards]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, ok := shard.entries[key]
+	if !ok || entry.expiresAt <= now {
+		return 0
+	}
+
+	return entry.count
+}
+
+func (s *memoryShard) sweep(now int64) {
+	for key, entry := range s.entries {
+		if entry.expiresAt <= now {
+			delete(s.entries, key)
+		}
+	}
+
+	s.nextSweep = now + memoryStoreSweepInterval
+}
+
+func (e *memoryEntry) takeFixed(maxRequests, window, cost, now int64) (int64, int64) {
+	if e.count == 0 {
+		e.expiresAt = now + window
+	}
+
+	if e.count+cost > maxRequests {
+		return -1, e.expiresAt - now
+	}
+
+	e.count += cost
+	return maxRequests - e.count, e.expiresAt - now
+}
+
+func (e *memoryEntry) takeSliding(maxRequests, window, cost, now int64) (int64, int64) {
+	i := 0
+	for i < len(e.log) && e.log[i] <= now-window {
+		i++
+	}
+	e.log = e.log[i:]
+
+	total := int64(len(e.log))
+	if total+cost > maxRequests {
+		index := total + cost - maxRequests - 1
+		if index >= total {
+			return -1, window
+		}
+
+		return -1, max(e.log[index]+window-now, 0)
+	}
+
+	for range cost {
+		e.log = append(e.log, now)
+	}
+	e.expiresAt = now + window
+
+	return maxRequests - int64(len(e.log)), max(e.log[0]+window-now, 0)
+}
+
+func (e *memoryEntry) takeSlidingCounter(maxRequests, window, cost, now int64) (int64, int64) {
+	current := now / window
+	elapsed := now - current*window
+
+	switch e.window {
+	case current:
+	case current - 1:
+		e.previous, e.count = e.count, 0
+	default:
+		e.previous, e.count = 0, 0
+	}
+	e.window = current
+
+	threshold := maxRequests - cost + 1
+
+	estimated := float64(e.previous)*float64(window-elapsed)/float64(window) + float64(e.count)
+	if estimated >= float64(threshold) {
+		var retryAfter float64
+		switch {
+		case threshold <= 0:
+			retryAfter = float64(window-elapsed) + float64(window)
+		case e.count < threshold:
+			retryAfter = float64(window-elapsed) - float64(threshold-e.count)*float64(window)/float64(e.previous)
+		default:
+			retryAfter = float64(window-elapsed) + float64(window) - float64(threshold)*float64(window)/float64(e.count)
+		}
+
+		return -1, max(int64(math.Ceil(retryAfter)), 1)
+	}
+
+	e.count += cost
+	e.expiresAt = now + 2*window - elapsed
+
+	return max(int64(math.Floor(float64(maxRequests)-estimated-float64(cost))), 0), window - elapsed
+}
+
+func (e *memoryEntry) takeTokenBucket(maxRequests, window, burst, cost, now int64) (int64, int64) {
+	refillRate := float64(maxRequests) / float64(window)
+
+	tokens := float64(burst)
+	if e.expiresAt != 0 {
+		elapsed := max(now-e.updatedAt, 0)
+		tokens = min(float64(burst), e.value+float64(elapsed)*refillRate)
+	}
+
+	allowed := tokens >= float64(cost)
+	if allowed {
+		tokens -= float64(cost)
+	}
+
+	fullIn := int64(math.Ceil((float64(burst) - tokens) / refillRate))
+	e.value = tokens
+	e.updatedAt = now
+	e.expiresAt = now + max(fullIn, 1)
+
+	if !allowed {
+		return -1, int64(math.Ceil((float64(cost) - tokens) / refillRate))
+	}
+
+	return int64(tokens), fullIn
+}
+
+func (e *memoryEntry) takeGCRA(maxRequests, window, burst, cost, now int64) (int64, int64) {
+	interval := float64(window) / float64(maxRequests)
+	tolerance := interval * float64(burst)
+
+	tat := max(e.value, float64(now))
+	newTat := tat + interval*float64(cost)
+
+	allowAt := newTat - tolerance
+	if allowAt > float64(now) {
+		return -1, int64(math.Ceil(allowAt - float64(now)))
+	}
+
+	resetIn := int64(math.Ceil(newTat - float64(now)))
+	e.value = newTat
+	e.expiresAt = now + resetIn
+
+	remaining := int64(math.Floor((tolerance - (newTat - float64(now))) / interval))
+	return max(remaining, 0), resetIn
+}
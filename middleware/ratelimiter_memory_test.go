@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"fmt"
+	"hash/maphash"
+	"testing"
+)
+
+type memoryTake struct {
+	at            int64
+	cost          int64
+	wantRemaining int64
+	wantReset     int64
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	// Windows of 1024ms keep the refill rates and intervals of token bucket
+	// and GCRA exact in floating point.
+	tests := []struct {
+		name   string
+		alg    limiterAlgorithm
+		max    int64
+		window int64
+		burst  int64
+		takes  []memoryTake
+	}{
+		{
+			name:   "fixed window resets",
+			alg:    algorithmFixed,
+			max:    2,
+			window: 1000,
+			takes: []memoryTake{
+				{at: 0, wantRemaining: 1, wantReset: 1000},
+				{at: 100, wantRemaining: 0, wantReset: 900},
+				{at: 200, wantRemaining: -1, wantReset: 800},
+				{at: 1000, wantRemaining: 1, wantReset: 1000},
+			},
+		},
+		{
+			name:   "fixed window cost",
+			alg:    algorithmFixed,
+			max:    3,
+			window: 1000,
+			takes: []memoryTake{
+				{at: 0, cost: 2, wantRemaining: 1, wantReset: 1000},
+				{at: 0, cost: 2, wantRemaining: -1, wantReset: 1000},
+				{at: 0, cost: 1, wantRemaining: 0, wantReset: 1000},
+			},
+		},
+		{
+			name:   "sliding window retries once the oldest request leaves",
+			alg:    algorithmSliding,
+			max:    2,
+			window: 1000,
+			takes: []memoryTake{
+				{at: 0, wantRemaining: 1, wantReset: 1000},
+				{at: 500, wantRemaining: 0, wantReset: 500},
+				{at: 600, wantRemaining: -1, wantReset: 400},
+				{at: 1000, wantRemaining: 0, wantReset: 500},
+			},
+		},
+		{
+			name:   "sliding counter weighs the previous window",
+			alg:    algorithmSlidingCounter,
+			max:    2,
+			window: 1000,
+			takes: []memoryTake{
+				{at: 0, wantRemaining: 1, wantReset: 1000},
+				{at: 100, wantRemaining: 0, wantReset: 900},
+				{at: 200, wantRemaining: -1, wantReset: 800},
+				{at: 1500, wantRemaining: 0, wantReset: 500},
+			},
+		},
+		{
+			name:   "token bucket refills",
+			alg:    algorithmTokenBucket,
+			max:    2,
+			window: 1024,
+			burst:  2,
+			takes: []memoryTake{
+				{at: 0, wantRemaining: 1, wantReset: 512},
+				{at: 0, wantRemaining: 0, wantReset: 1024},
+				{at: 0, wantRemaining: -1, wantReset: 512},
+				{at: 256, wantRemaining: -1, wantReset: 256},
+				{at: 512, wantRemaining: 0, wantReset: 1024},
+				{at: 4096, wantRemaining: 1, wantReset: 512},
+			},
+		},
+		{
+			name:   "gcra spaces requests after the burst",
+			alg:    algorithmGCRA,
+			max:    2,
+			window: 1024,
+			burst:  2,
+			takes: []memoryTake{
+				{at: 0, wantRemaining: 1, wantReset: 512},
+				{at: 0, wantRemaining: 0, wantReset: 1024},
+				{at: 0, wantRemaining: -1, wantReset: 512},
+				{at: 512, wantRemaining: 0, wantReset: 1024},
+				{at: 768, wantRemaining: -1, wantReset: 256},
+				{at: 1024, wantRemaining: 0, wantReset: 1024},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMemoryStore()
+
+			for i, take := range tt.takes {
+				cost := max(take.cost, 1)
+
+				remaining, reset := s.take(tt.alg, "key", tt.max, tt.window, tt.burst, cost, take.at)
+				if remaining != take.wantRemaining || reset != take.wantReset {
+					t.Fatalf("take %d at %dms: got remaining %d and reset %dms, want %d and %dms", i, take.at, remaining, reset, take.wantRemaining, take.wantReset)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s := newMemoryStore()
+
+	// Find another key in the same shard, as only the shard being used is
+	// swept.
+	shard := &s.shards[maphash.String(s.seed, "expired")%memoryStoreShards]
+	other := ""
+	for i := 0; other == ""; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if &s.shards[maphash.String(s.seed, key)%memoryStoreShards] == shard {
+			other = key
+		}
+	}
+
+	s.take(algorithmFixed, "expired", 1, 1000, 0, 1, 0)
+
+	// Expired entries are kept until the shard is swept, but no longer
+	// counted.
+	s.take(algorithmFixed, other, 1, 1000, 0, 1, memoryStoreSweepInterval/2)
+	if _, ok := shard.entries["expired"]; !ok {
+		t.Fatalf("expired entry was evicted before the sweep interval")
+	}
+	if count := s.count("expired", memoryStoreSweepInterval/2); count != 0 {
+		t.Fatalf("got count %d for expired entry, want 0", count)
+	}
+
+	s.take(algorithmFixed, other, 1, 1000, 0, 1, memoryStoreSweepInterval)
+	if _, ok := shard.entries["expired"]; ok {
+		t.Fatalf("expired entry was not evicted by the sweep")
+	}
+	if _, ok := shard.entries[other]; !ok {
+		t.Fatalf("entry in use was evicted by the sweep")
+	}
+}
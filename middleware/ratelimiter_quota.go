@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/BurakYs/go-api-example/httperror"
+)
+
+// QuotaPeriod is the calendar period, in UTC, a quota counts requests over.
+type QuotaPeriod string
+
+const (
+	QuotaDaily   QuotaPeriod = "daily"
+	QuotaMonthly QuotaPeriod = "monthly"
+)
+
+// bounds returns the start and end of the period containing now.
+func (p QuotaPeriod) bounds(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+
+	if p == QuotaMonthly {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// Quota allows Max requests per calendar period and is meant to be stacked
+// with a short window limit. Window and Burst are ignored. It is named after
+// the period unless WithName is used, so it is shared by every route it is
+// mounted on.
+func (rl *RateLimiter) Quota(period QuotaPeriod) *RateLimiterBuilder {
+	b := &RateLimiterBuilder{
+		rateLimiter: rl,
+		config:      rl.defaultCfg,
+		algorithm:   algorithmFixed,
+		period:      period,
+	}
+
+	b.config.Name = "quota_" + string(period)
+	return b
+}
+
+// QuotaUsage describes how much of a quota the client has used.
+type QuotaUsage struct {
+	Name      string    `json:"name"`
+	Period    string    `json:"period"`
+	Limit     int       `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetsAt  time.Time `json:"resetsAt"`
+}
+
+// Peek reads the usage of a quota without counting a request. It returns nil
+// when the quota does not apply to the client.
+func (b *RateLimiterBuilder) Peek(c fiber.Ctx) (*QuotaUsage, error) {
+	if b.period == "" {
+		return nil, errors.New("peek is only supported on quotas")
+	}
+
+	if !b.config.Enabled {
+		return nil, nil
+	}
+
+	id := b.config.KeyFunc(c)
+	if id == "" {
+		return nil, nil
+	}
+
+	limit := b.limit(c)
+	if limit.Max <= 0 {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	start, end := b.period.bounds(now)
+	key := "rate_limit:" + b.namespace(c) + ":" + id + ":" + start.Format("20060102")
+
+	used, err := b.count(c, key, now.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuotaUsage{
+		Name:      b.namespace(c),
+		Period:    string(b.period),
+		Limit:     limit.Max,
+		Used:      used,
+		Remaining: max(int64(limit.Max)-used, 0),
+		ResetsAt:  end,
+	}, nil
+}
+
+func (b *RateLimiterBuilder) count(c fiber.Ctx, key string, now int64) (int64, error) {
+	if b.config.Backend == BackendMemory {
+		return b.rateLimiter.memory.count(key, now), nil
+	}
+
+	used, err := b.rateLimiter.redis.Client().Get(c, key).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		b.redisFailed(err)
+
+		switch b.config.FailurePolicy {
+		case FailOpen, FailClosed:
+			return 0, httperror.New(fiber.StatusServiceUnavailable, "Service is temporarily unavailable")
+		default:
+			return b.rateLimiter.memory.count(key, now), nil
+		}
+	}
+
+	b.redisSucceeded()
+	return used, nil
+}
+
+// QuotaHandler responds with the client's usage of each quota, leaving out
+// the ones that don't apply to it.
+func (rl *RateLimiter) QuotaHandler(quotas ...*RateLimiterBuilder) fiber.Handler {
+	for _, b := range quotas {
+		b.prepare()
+	}
+
+	return func(c fiber.Ctx) error {
+		usages := make([]QuotaUsage, 0, len(quotas))
+
+		for _, b := range quotas {
+			usage, err := b.Peek(c)
+			if err != nil {
+				return err
+			}
+
+			if usage != nil {
+				usages = append(usages, *usage)
+			}
+		}
+
+		return c.JSON(usages)
+	}
+}
@@ -2,27 +2,37 @@ local key = KEYS[1]
 local max = tonumber(ARGV[1])
 local window = tonumber(ARGV[2])
 local now = tonumber(ARGV[3])
+local requestID = ARGV[5]
+local cost = tonumber(ARGV[6])
 
-redis.call("ZREMRANGEBYSCORE", key, 0, now - window * 1000)
+redis.call("ZREMRANGEBYSCORE", key, 0, now - window)
 
 local total = redis.call("ZCARD", key)
-if total < max then
-  redis.call("ZADD", key, now, tostring(now))
-  redis.call("PEXPIRE", key, window * 1000)
+if total + cost <= max then
+  -- Requests arriving in the same millisecond need distinct members, or
+  -- they would overwrite each other and be undercounted.
+  for i = 1, cost do
+    redis.call("ZADD", key, now, tostring(now) .. ":" .. requestID .. ":" .. i)
+  end
+  redis.call("PEXPIRE", key, window)
 
   local earliest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
   local earliestScore = tonumber(earliest[2])
-  local waitMs = (earliestScore + window * 1000) - now
+  local waitMs = (earliestScore + window) - now
   if waitMs < 0 then waitMs = 0 end
-  local resetSeconds = math.floor((waitMs + 999) / 1000)
 
-  local remaining = max - total - 1
-  return {remaining, resetSeconds}
+  local remaining = max - total - cost
+  return {remaining, waitMs}
 else
-  local earliest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
-  local earliestScore = tonumber(earliest[2])
-  local waitMs = (earliestScore + window * 1000) - now
+  -- The request fits once enough of the oldest entries have expired. A
+  -- cost above max never fits, so the client is told to wait a window.
+  local index = total + cost - max - 1
+  local waitMs = window
+  if index < total then
+    local entry = redis.call("ZRANGE", key, index, index, "WITHSCORES")
+    waitMs = (tonumber(entry[2]) + window) - now
+  end
+
   if waitMs < 0 then waitMs = 0 end
-  local resetSeconds = math.floor((waitMs + 999) / 1000)
-  return {-1, resetSeconds}
-end
\ No newline at end of file
+  return {-1, waitMs}
+end
@@ -0,0 +1,47 @@
+-- Sliding window counter: keeps the counts of the current and previous
+-- fixed windows and weighs the previous one by how much of it still
+-- overlaps the sliding window, assuming its requests were evenly spread.
+local key = KEYS[1]
+local max = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[6])
+
+local current = math.floor(now / window)
+local elapsed = now - current * window
+
+local state = redis.call("HMGET", key, "window", "current", "previous")
+local stored = tonumber(state[1])
+local currentCount = 0
+local previousCount = 0
+if stored == current then
+  currentCount = tonumber(state[2]) or 0
+  previousCount = tonumber(state[3]) or 0
+elseif stored == current - 1 then
+  previousCount = tonumber(state[2]) or 0
+end
+
+-- The request is allowed while the estimate stays under threshold, which
+-- leaves room for all of its cost.
+local threshold = max - cost + 1
+
+local estimated = previousCount * (window - elapsed) / window + currentCount
+if estimated >= threshold then
+  local retryAfter
+  if threshold <= 0 then
+    retryAfter = (window - elapsed) + window
+  elseif currentCount < threshold then
+    retryAfter = window - elapsed - (threshold - currentCount) * window / previousCount
+  else
+    retryAfter = (window - elapsed) + window - threshold * window / currentCount
+  end
+
+  return {-1, math.max(math.ceil(retryAfter), 1)}
+end
+
+currentCount = currentCount + cost
+redis.call("HSET", key, "window", current, "current", currentCount, "previous", previousCount)
+redis.call("PEXPIRE", key, 2 * window - elapsed)
+
+local remaining = math.floor(max - estimated - cost)
+return {math.max(remaining, 0), window - elapsed}
@@ -0,0 +1,38 @@
+-- Token bucket holding up to burst tokens, refilled with max tokens per
+-- window. Each request takes as many tokens as it costs.
+local key = KEYS[1]
+local max = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local burst = tonumber(ARGV[4])
+local cost = tonumber(ARGV[6])
+
+local refillRate = max / window
+
+local bucket = redis.call("HMGET", key, "tokens", "updated_at")
+local tokens = tonumber(bucket[1])
+local updatedAt = tonumber(bucket[2])
+if tokens == nil or updatedAt == nil then
+  tokens = burst
+  updatedAt = now
+end
+
+local elapsed = now - updatedAt
+if elapsed < 0 then elapsed = 0 end
+tokens = math.min(burst, tokens + elapsed * refillRate)
+
+local allowed = tokens >= cost
+if allowed then
+  tokens = tokens - cost
+end
+
+-- A missing bucket is a full one, so it only needs to live until refilled.
+local fullIn = math.ceil((burst - tokens) / refillRate)
+redis.call("HSET", key, "tokens", tokens, "updated_at", now)
+redis.call("PEXPIRE", key, math.max(fullIn, 1))
+
+if not allowed then
+  return {-1, math.ceil((cost - tokens) / refillRate)}
+end
+
+return {math.floor(tokens), fullIn}
@@ -2,34 +2,52 @@ package middleware
 
 import (
 	"errors"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 
 	"github.com/BurakYs/go-api-example/app/session"
 	"github.com/BurakYs/go-api-example/httperror"
+	"github.com/BurakYs/go-api-example/util/rbac"
 	"github.com/BurakYs/go-api-example/util/rctx"
 )
 
 type RequireAuth struct {
 	service    *session.Service
 	cookieName string
+	rbac       *rbac.RBAC
+	accounts   *accountCache
 }
 
-func NewRequireAuth(service *session.Service, cookieName string) *RequireAuth {
+// NewRequireAuth checks sessions against the accounts returned by
+// loadAccount, caching them for accountTTL.
+func NewRequireAuth(service *session.Service, cookieName string, rbac *rbac.RBAC, loadAccount AccountLoader, accountTTL time.Duration) *RequireAuth {
 	return &RequireAuth{
 		service:    service,
 		cookieName: cookieName,
+		rbac:       rbac,
+		accounts:   newAccountCache(loadAccount, accountTTL),
 	}
 }
 
 func (m *RequireAuth) Middleware() fiber.Handler {
+	return m.handler(false)
+}
+
+// AllowRestricted also lets restricted sessions through, for the few routes
+// such sessions need to get back to a normal state.
+func (m *RequireAuth) AllowRestricted() fiber.Handler {
+	return m.handler(true)
+}
+
+func (m *RequireAuth) handler(allowRestricted bool) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		sid := c.Cookies(m.cookieName)
 		if sid == "" {
 			return httperror.New(fiber.StatusUnauthorized, "Unauthorized")
 		}
 
-		userID, err := m.service.GetUserID(c, sid)
+		sess, err := m.service.Get(c, sid)
 		if err != nil {
 			if errors.Is(err, session.ErrNotFound) {
 				return httperror.New(fiber.StatusUnauthorized, "Unauthorized")
@@ -38,8 +56,50 @@ func (m *RequireAuth) Middleware() fiber.Handler {
 			return err
 		}
 
-		rctx.SetUserID(c, userID)
+		// A session is only valid on the tenant it was created for, no matter
+		// which tenant the request asks for.
+		if sess.TenantID != rctx.GetTenantID(c) {
+			return httperror.New(fiber.StatusUnauthorized, "Unauthorized")
+		}
+
+		if sess.Restricted && !allowRestricted {
+			return httperror.New(fiber.StatusForbidden, "Password change required")
+		}
+
+		// Impersonation ends along with the admin's own session, e.g. when it
+		// is revoked or expires.
+		if sess.ImpersonatorSessionID != "" {
+			exists, err := m.service.Exists(c, sess.ImpersonatorSessionID)
+			if err != nil {
+				return err
+			}
+
+			if !exists {
+				return httperror.New(fiber.StatusUnauthorized, "Unauthorized")
+			}
+		}
+
+		// Roles and plan are read from the account rather than the session, so
+		// changes take effect without waiting for the session to expire.
+		account, err := m.accounts.get(c, sess.TenantID, sess.UserID)
+		if err != nil {
+			if errors.Is(err, ErrAccountNotFound) {
+				return httperror.New(fiber.StatusUnauthorized, "Unauthorized")
+			}
+
+			return err
+		}
+
+		if account.Disabled {
+			return httperror.New(fiber.StatusForbidden, "This account is disabled")
+		}
+
+		rctx.SetUserID(c, sess.UserID)
+		rctx.SetRoles(c, account.Roles)
+		rctx.SetPlan(c, account.Plan)
+		rctx.SetPermissions(c, m.rbac.Permissions(account.Roles))
 		rctx.SetSessionID(c, sid)
+		rctx.SetImpersonatorID(c, sess.ImpersonatorID)
 
 		return c.Next()
 	}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/httperror"
+	"github.com/BurakYs/go-api-example/util/rbac"
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+// RequirePermission must run after RequireAuth, which loads the session's
+// permissions into the request context.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if !rbac.HasPermission(rctx.GetPermissions(c), permission) {
+			return httperror.New(fiber.StatusForbidden, "Forbidden")
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/BurakYs/go-api-example/app/session"
+	"github.com/BurakYs/go-api-example/httperror"
+	"github.com/BurakYs/go-api-example/util/rctx"
+	"github.com/BurakYs/go-api-example/util/tenant"
+)
+
+type TenantResolverConfig struct {
+	Header     string
+	BaseDomain string
+	Default    string
+	CookieName string
+}
+
+// TenantResolver stores the tenant of every request in rctx. Repositories
+// scope their queries by it, so it must run before any route touching them.
+type TenantResolver struct {
+	service *session.Service
+	config  TenantResolverConfig
+}
+
+func NewTenantResolver(service *session.Service, config TenantResolverConfig) *TenantResolver {
+	return &TenantResolver{
+		service: service,
+		config:  config,
+	}
+}
+
+func (m *TenantResolver) Middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		tenantID, err := m.resolve(c)
+		if err != nil {
+			return err
+		}
+
+		if tenantID == "" {
+			return httperror.New(fiber.StatusBadRequest, "Tenant could not be resolved")
+		}
+
+		if !tenant.IsValid(tenantID) {
+			return httperror.New(fiber.StatusBadRequest, "Invalid tenant")
+		}
+
+		rctx.SetTenantID(c, tenantID)
+		return c.Next()
+	}
+}
+
+func (m *TenantResolver) resolve(c fiber.Ctx) (string, error) {
+	sessionTenant, err := m.sessionTenant(c)
+	if err != nil {
+		return "", err
+	}
+
+	// Clients can send any header, so it is only read when configured and
+	// can't point a session at another tenant than its own.
+	if m.config.Header != "" {
+		if id := strings.ToLower(c.Get(m.config.Header)); id != "" {
+			if sessionTenant != "" && id != sessionTenant {
+				return "", httperror.New(fiber.StatusForbidden, "Session does not belong to this tenant")
+			}
+
+			return id, nil
+		}
+	}
+
+	if id := m.subdomain(c.Hostname()); id != "" {
+		return id, nil
+	}
+
+	if sessionTenant != "" {
+		return sessionTenant, nil
+	}
+
+	return m.config.Default, nil
+}
+
+// sessionTenant returns the tenant of the request's session, or an empty
+// string when it has none.
+func (m *TenantResolver) sessionTenant(c fiber.Ctx) (string, error) {
+	sid := c.Cookies(m.config.CookieName)
+	if sid == "" {
+		return "", nil
+	}
+
+	sess, err := m.service.Get(c, sid)
+	if err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return sess.TenantID, nil
+}
+
+// subdomain returns the label right below the base domain, e.g. "acme" for
+// api.acme.example.com with a base domain of example.com.
+func (m *TenantResolver) subdomain(host string) string {
+	if m.config.BaseDomain == "" {
+		return ""
+	}
+
+	host = strings.ToLower(host)
+	prefix, ok := strings.CutSuffix(host, "."+strings.ToLower(m.config.BaseDomain))
+	if !ok {
+		return ""
+	}
+
+	labels := strings.Split(prefix, ".")
+	return labels[len(labels)-1]
+}
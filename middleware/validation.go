@@ -26,25 +26,59 @@ const (
 )
 
 func ValidateBody[T any](c fiber.Ctx) (*T, error) {
-	return validate[T](c, bindingBody)
+	return validateOnce[T](c, bindingBody)
 }
 
 func ValidateQuery[T any](c fiber.Ctx) (*T, error) {
-	return validate[T](c, bindingQuery)
+	return validateOnce[T](c, bindingQuery)
 }
 
 func ValidateParams[T any](c fiber.Ctx) (*T, error) {
-	return validate[T](c, bindingParams)
+	return validateOnce[T](c, bindingParams)
 }
 
 func ValidateForm[T any](c fiber.Ctx) (*T, error) {
-	return validate[T](c, bindingForm)
+	return validateOnce[T](c, bindingForm)
+}
+
+// NewValidationError builds the error returned for invalid input so checks
+// done outside of struct validation respond in the same shape.
+func NewValidationError(failures ...ValidationFailure) *httperror.HTTPError {
+	if failures == nil {
+		failures = []ValidationFailure{}
+	}
+
+	return httperror.New(fiber.StatusBadRequest, "Invalid parameters provided").WithExtra("validationFailures", failures)
 }
 
 type normalizable interface {
 	Normalize()
 }
 
+// validatedKey stores the outcome of validating a T from location in Locals.
+type validatedKey[T any] struct {
+	location string
+}
+
+type validated[T any] struct {
+	data *T
+	err  error
+}
+
+// validateOnce reuses the outcome of an earlier validation of the same type
+// in the request, e.g. by a rate limiter keyed by the body, so the input is
+// only bound and normalized once.
+func validateOnce[T any](c fiber.Ctx, location string) (*T, error) {
+	key := validatedKey[T]{location: location}
+	if result, ok := c.Locals(key).(validated[T]); ok {
+		return result.data, result.err
+	}
+
+	data, err := validate[T](c, location)
+	c.Locals(key, validated[T]{data: data, err: err})
+	return data, err
+}
+
 func validate[T any](c fiber.Ctx, location string) (*T, error) {
 	data := new(T)
 	var err error
@@ -61,7 +95,7 @@ func validate[T any](c fiber.Ctx, location string) (*T, error) {
 	}
 
 	if err != nil {
-		return data, httperror.New(fiber.StatusBadRequest, "Invalid parameters provided").WithExtra("validationFailures", []ValidationFailure{})
+		return data, NewValidationError()
 	}
 
 	if n, ok := any(data).(normalizable); ok {
@@ -81,10 +115,10 @@ func validate[T any](c fiber.Ctx, location string) (*T, error) {
 				}
 			}
 
-			return data, httperror.New(fiber.StatusBadRequest, "Invalid parameters provided").WithExtra("validationFailures", failures)
+			return data, NewValidationError(failures...)
 		}
 
-		return data, httperror.New(fiber.StatusBadRequest, "Invalid parameters provided").WithExtra("validationFailures", []ValidationFailure{})
+		return data, NewValidationError()
 	}
 
 	return data, nil
@@ -2,24 +2,47 @@ package main
 
 import (
 	"context"
+	"expvar"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/adaptor"
 	loggermi "github.com/gofiber/fiber/v3/middleware/logger"
 	"github.com/gofiber/fiber/v3/middleware/recover"
 	"go.uber.org/zap"
 
+	"github.com/BurakYs/go-api-example/app/organization"
+	"github.com/BurakYs/go-api-example/app/user"
+	"github.com/BurakYs/go-api-example/config"
 	"github.com/BurakYs/go-api-example/httperror"
 	"github.com/BurakYs/go-api-example/middleware"
+	"github.com/BurakYs/go-api-example/util/rctx"
 )
 
 type Server struct {
 	app *fiber.App
 }
 
-func NewServer(logger *zap.Logger) *Server {
+func NewServer(logger *zap.Logger, proxyCfg *config.ProxyConfig) *Server {
+	proxies := make([]string, len(proxyCfg.TrustedPrefixes))
+	for i, prefix := range proxyCfg.TrustedPrefixes {
+		proxies[i] = prefix.String()
+	}
+
+	// c.IP() only understands single address headers, so it is left
+	// pointing at the connection for Forwarded. Use rctx.GetClientIP instead.
+	proxyHeader := proxyCfg.Header
+	if strings.EqualFold(proxyHeader, fiber.HeaderForwarded) {
+		proxyHeader = ""
+	}
+
 	app := fiber.New(fiber.Config{
-		ErrorHandler:  middleware.ErrorHandler,
-		CaseSensitive: true,
+		ErrorHandler:       middleware.ErrorHandler,
+		CaseSensitive:      true,
+		TrustProxy:         len(proxies) > 0,
+		TrustProxyConfig:   fiber.TrustProxyConfig{Proxies: proxies},
+		ProxyHeader:        proxyHeader,
+		EnableIPValidation: true,
 	})
 
 	app.Use(
@@ -27,7 +50,7 @@ func NewServer(logger *zap.Logger) *Server {
 		loggermi.New(loggermi.Config{
 			LoggerFunc: func(c fiber.Ctx, data *loggermi.Data, _ *loggermi.Config) error {
 				logger.Info("HTTP Request",
-					zap.String("ip", c.IP()),
+					zap.String("ip", rctx.GetClientIP(c)),
 					zap.Int("status", c.Response().StatusCode()),
 					zap.Duration("latency", data.Stop.Sub(data.Start)),
 					zap.String("method", c.Method()),
@@ -37,6 +60,10 @@ func NewServer(logger *zap.Logger) *Server {
 				return nil
 			},
 		}),
+		middleware.ClientIP(middleware.ClientIPConfig{
+			Header:         proxyCfg.Header,
+			TrustedProxies: proxyCfg.TrustedPrefixes,
+		}),
 	)
 
 	return &Server{
@@ -49,19 +76,156 @@ func (s *Server) SetupRoutes(deps *Dependencies) {
 		return c.SendString("OK")
 	})
 
+	s.app.Use(deps.IPAccessHandler.Enforce())
+	s.app.Use(deps.TenantResolver.Middleware())
+
+	// Limits referring to a policy by name can be changed at runtime through
+	// /admin/rate-limit-policies, e.g. to tighten registration during an
+	// attack. Until a policy is saved the configured limits apply.
 	auth := s.app.Group("/auth")
-	auth.Post("/register", deps.RateLimiter.Middleware(), deps.UserHandler.Register)
-	auth.Post("/login", deps.RateLimiter.Middleware(), deps.UserHandler.Login)
-	auth.Post("/logout", deps.RequireAuth.Middleware(), deps.UserHandler.Logout)
+	// Hashing passwords is slow, so routes doing it also cap the requests
+	// each client may have in flight.
+	auth.Post("/register", s.ipRateLimit(deps, "register").Middleware(), deps.ConcurrencyLimiter.Middleware(), deps.UserHandler.Register)
+	auth.Post("/login", s.loginRateLimit(deps), deps.ConcurrencyLimiter.Middleware(), deps.UserHandler.Login)
+	auth.Post("/password-reset", s.ipRateLimit(deps, "password_reset").Middleware(), deps.ConcurrencyLimiter.Middleware(), deps.UserHandler.ResetPassword)
+	auth.Post("/logout", deps.RequireAuth.AllowRestricted(), deps.UserHandler.Logout)
+	auth.Post("/impersonation/stop", deps.RequireAuth.Middleware(), deps.UserHandler.StopImpersonation)
 
 	users := s.app.Group("/users")
-	users.Get("/me", deps.RequireAuth.Middleware(), deps.UserHandler.Me)
+	users.Get("/me", deps.RequireAuth.AllowRestricted(), deps.UserHandler.Me)
+	users.Get("/me/quota", deps.RequireAuth.Middleware(), s.quotaUsage(deps))
+	users.Post("/me/password", s.ipRateLimit(deps, "change_password").Middleware(), deps.ConcurrencyLimiter.Middleware(), deps.RequireAuth.AllowRestricted(), middleware.DenyImpersonation(), deps.UserHandler.ChangePassword)
+
+	// Impersonating admins may look at a user's organizations but not
+	// change them on the user's behalf.
+	orgs := s.app.Group("/organizations", deps.RequireAuth.Middleware(), s.planRateLimit(deps, 1))
+	orgs.Post("/", middleware.DenyImpersonation(), deps.OrgHandler.Create)
+	orgs.Get("/", deps.OrgHandler.List)
+	orgs.Post("/invitations/accept", middleware.DenyImpersonation(), deps.OrgHandler.AcceptInvitation)
+	orgs.Get("/:orgId", deps.OrgHandler.RequireRole(organization.RoleMember), deps.OrgHandler.Get)
+	orgs.Get("/:orgId/members", deps.OrgHandler.RequireRole(organization.RoleMember), deps.OrgHandler.ListMembers)
+	orgs.Patch("/:orgId/members/:userId", middleware.DenyImpersonation(), deps.OrgHandler.RequireRole(organization.RoleAdmin), deps.OrgHandler.UpdateMember)
+	orgs.Delete("/:orgId/members/:userId", middleware.DenyImpersonation(), deps.OrgHandler.RequireRole(organization.RoleMember), deps.OrgHandler.RemoveMember)
+	orgs.Post("/:orgId/invitations", middleware.DenyImpersonation(), deps.OrgHandler.RequireRole(organization.RoleAdmin), deps.OrgHandler.Invite)
+	orgs.Get("/:orgId/invitations", deps.OrgHandler.RequireRole(organization.RoleAdmin), deps.OrgHandler.ListInvitations)
+	orgs.Delete("/:orgId/invitations/:invitationId", middleware.DenyImpersonation(), deps.OrgHandler.RequireRole(organization.RoleAdmin), deps.OrgHandler.RevokeInvitation)
+
+	admin := s.app.Group("/admin", deps.RequireAuth.Middleware(), middleware.DenyImpersonation())
+	admin.Get("/audit-logs", middleware.RequirePermission("audit:read"), deps.AuditHandler.List)
+
+	// Metrics include memory stats and the command line of the process, so
+	// they are only served to admins.
+	if deps.config.App.Metrics {
+		admin.Get("/metrics", middleware.RequirePermission("metrics:read"), adaptor.HTTPHandler(expvar.Handler()))
+	}
+
+	invites := admin.Group("/invites")
+	invites.Get("/", middleware.RequirePermission("invites:read"), deps.InviteHandler.List)
+	invites.Post("/", middleware.RequirePermission("invites:write"), deps.InviteHandler.Issue)
+	invites.Delete("/:id", middleware.RequirePermission("invites:write"), deps.InviteHandler.Revoke)
+
+	ipAccess := admin.Group("/ip-access")
+	ipAccess.Get("/rules", middleware.RequirePermission("ipaccess:read"), deps.IPAccessHandler.ListRules)
+	ipAccess.Post("/rules", middleware.RequirePermission("ipaccess:write"), deps.IPAccessHandler.AddRule)
+	ipAccess.Delete("/rules", middleware.RequirePermission("ipaccess:write"), deps.IPAccessHandler.RemoveRule)
+	ipAccess.Get("/bans", middleware.RequirePermission("ipaccess:read"), deps.IPAccessHandler.ListBans)
+	ipAccess.Post("/bans", middleware.RequirePermission("ipaccess:write"), deps.IPAccessHandler.Ban)
+	ipAccess.Delete("/bans/:ip", middleware.RequirePermission("ipaccess:write"), deps.IPAccessHandler.Unban)
+
+	policies := admin.Group("/rate-limit-policies")
+	policies.Get("/", middleware.RequirePermission("ratelimit:read"), deps.PolicyHandler.List)
+	policies.Put("/:name", middleware.RequirePermission("ratelimit:write"), deps.PolicyHandler.Save)
+	policies.Delete("/:name", middleware.RequirePermission("ratelimit:write"), deps.PolicyHandler.Delete)
+
+	adminUsers := admin.Group("/users")
+	adminUsers.Get("/", middleware.RequirePermission("users:read"), deps.AdminUserHandler.List)
+	adminUsers.Get("/search", middleware.RequirePermission("users:read"), deps.ConcurrencyLimiter.Middleware(), deps.AdminUserHandler.Search)
+	adminUsers.Get("/:id", middleware.RequirePermission("users:read"), deps.AdminUserHandler.Get)
+	adminUsers.Post("/:id/disable", middleware.RequirePermission("users:write"), deps.AdminUserHandler.Disable)
+	adminUsers.Post("/:id/enable", middleware.RequirePermission("users:write"), deps.AdminUserHandler.Enable)
+	adminUsers.Post("/:id/plan", middleware.RequirePermission("users:write"), deps.AdminUserHandler.SetPlan)
+	adminUsers.Post("/:id/logout", middleware.RequirePermission("users:write"), deps.AdminUserHandler.Logout)
+	adminUsers.Post("/:id/password-reset", middleware.RequirePermission("users:write"), deps.AdminUserHandler.ResetPassword)
+	adminUsers.Post("/:id/impersonate", middleware.RequirePermission("users:impersonate"), deps.AdminUserHandler.Impersonate)
 
 	s.app.Use(func(c fiber.Ctx) error {
 		return httperror.New(fiber.StatusNotFound, "Page not found")
 	})
 }
 
+// loginRateLimit limits login attempts both per IP and per account. The
+// per-account limit trades availability for resistance to distributed
+// guessing: attempts by anyone count against the account, so its owner can
+// be locked out until the window ends.
+func (s *Server) loginRateLimit(deps *Dependencies) fiber.Handler {
+	perAccount := deps.RateLimiter.Fixed().
+		WithName("login_account").
+		WithPolicy("login_account").
+		WithMax(deps.config.RateLimit.AccountRequests).
+		WithWindow(deps.config.RateLimit.AccountWindow).
+		WithKeyFunc(middleware.KeyFromBody(func(c fiber.Ctx, body *user.LoginBody) string {
+			return rctx.GetTenantID(c) + ":" + body.Email
+		}))
+
+	return deps.RateLimiter.Stack(s.ipRateLimit(deps, "login"), perAccount)
+}
+
+// ipRateLimit limits clients by IP with the named policy. Unlike limits
+// keyed by account or user, its rejections count towards banning the IP.
+func (s *Server) ipRateLimit(deps *Dependencies, policy string) *middleware.RateLimiterBuilder {
+	return deps.RateLimiter.Fixed().WithPolicy(policy).WithOnReject(deps.recordOffense)
+}
+
+// planRateLimit limits authenticated users by their plan, both over a short
+// window and with daily and monthly quotas. Each request to the route counts
+// as cost requests.
+func (s *Server) planRateLimit(deps *Dependencies, cost int) fiber.Handler {
+	short, daily, monthly := s.planLimits(deps)
+	return deps.RateLimiter.Stack(short.WithCost(cost), daily.WithCost(cost), monthly.WithCost(cost))
+}
+
+// quotaUsage shows users how much of their plan's quotas they have left.
+func (s *Server) quotaUsage(deps *Dependencies) fiber.Handler {
+	_, daily, monthly := s.planLimits(deps)
+	return deps.RateLimiter.QuotaHandler(daily, monthly)
+}
+
+func (s *Server) planLimits(deps *Dependencies) (*middleware.RateLimiterBuilder, *middleware.RateLimiterBuilder, *middleware.RateLimiterBuilder) {
+	planOf := func(c fiber.Ctx) config.PlanLimits {
+		if limits, ok := deps.config.RateLimit.Plans[rctx.GetPlan(c)]; ok {
+			return limits
+		}
+
+		return deps.config.RateLimit.Plans[deps.config.RateLimit.DefaultPlan]
+	}
+
+	byUser := func(c fiber.Ctx) string {
+		return rctx.GetUserID(c)
+	}
+
+	short := deps.RateLimiter.Fixed().
+		WithName("plan").
+		WithKeyFunc(byUser).
+		WithLimitFunc(func(c fiber.Ctx) (middleware.Limit, bool) {
+			limits := planOf(c)
+			return middleware.Limit{Max: limits.Requests, Window: limits.Window}, true
+		})
+
+	daily := deps.RateLimiter.Quota(middleware.QuotaDaily).
+		WithKeyFunc(byUser).
+		WithLimitFunc(func(c fiber.Ctx) (middleware.Limit, bool) {
+			return middleware.Limit{Max: planOf(c).DailyQuota}, true
+		})
+
+	monthly := deps.RateLimiter.Quota(middleware.QuotaMonthly).
+		WithKeyFunc(byUser).
+		WithLimitFunc(func(c fiber.Ctx) (middleware.Limit, bool) {
+			return middleware.Limit{Max: planOf(c).MonthlyQuota}, true
+		})
+
+	return short, daily, monthly
+}
+
 func (s *Server) Listen(port string) error {
 	return s.app.Listen(":"+port, fiber.ListenConfig{
 		DisableStartupMessage: true,
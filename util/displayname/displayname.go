@@ -0,0 +1,118 @@
+package displayname
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize composes the name to NFC, so "Zoë" typed with a combining
+// diaeresis is stored the same way as with a precomposed ë, and collapses
+// runs of whitespace into single spaces.
+func Normalize(name string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(name)), " ")
+}
+
+// IsValid reports whether name consists of words of letters, optionally
+// followed by combining marks, separated by single spaces, apostrophes or
+// hyphens, e.g. "Zoë", "Çağrı", "李小龍", "O'Brien" or "Anne-Marie".
+func IsValid(name string) bool {
+	afterLetter := false
+
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r):
+			afterLetter = true
+		case unicode.IsMark(r):
+			if !afterLetter {
+				return false
+			}
+		case isSeparator(r):
+			if !afterLetter {
+				return false
+			}
+			afterLetter = false
+		default:
+			return false
+		}
+	}
+
+	return afterLetter
+}
+
+func isSeparator(r rune) bool {
+	switch r {
+	case ' ', '\'', '’', '-', '‐':
+		return true
+	}
+
+	return false
+}
+
+// scriptSets are the script combinations allowed in a single name, following
+// the Highly Restrictive level of Unicode TS #39: Japanese, Chinese and
+// Korean names may mix Han with their own scripts and Latin.
+var scriptSets = [][]*unicode.RangeTable{
+	{unicode.Latin, unicode.Han, unicode.Hiragana, unicode.Katakana},
+	{unicode.Latin, unicode.Han, unicode.Bopomofo},
+	{unicode.Latin, unicode.Han, unicode.Hangul},
+}
+
+// IsSingleScript reports whether the letters of name all come from one
+// script, or from one of the combinations used to write CJK names. Names
+// mixing scripts such as "Pаypal" with a Cyrillic а are confusable with
+// other names and are used to impersonate them.
+func IsSingleScript(name string) bool {
+	var scripts []*unicode.RangeTable
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		script := scriptOf(r)
+		if script == nil || slices.Contains(scripts, script) {
+			continue
+		}
+
+		scripts = append(scripts, script)
+	}
+
+	if len(scripts) <= 1 {
+		return true
+	}
+
+	for _, set := range scriptSets {
+		if coversAll(set, scripts) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func scriptOf(r rune) *unicode.RangeTable {
+	for name, table := range unicode.Scripts {
+		if name == "Common" || name == "Inherited" {
+			continue
+		}
+
+		if unicode.Is(table, r) {
+			return table
+		}
+	}
+
+	return nil
+}
+
+func coversAll(set, scripts []*unicode.RangeTable) bool {
+	for _, script := range scripts {
+		if !slices.Contains(set, script) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,110 @@
+package emaildomain
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed disposable.txt
+var embeddedDisposable string
+
+// RejectedError is returned for addresses the policy does not accept. Its
+// message is meant to be shown to the user.
+type RejectedError struct {
+	Message string
+}
+
+func (e *RejectedError) Error() string {
+	return e.Message
+}
+
+var (
+	errNotAllowed = &RejectedError{Message: "Email addresses from this domain are not allowed"}
+	errDisposable = &RejectedError{Message: "Disposable email addresses are not allowed"}
+)
+
+type Policy struct {
+	allowed    map[string]struct{}
+	denied     map[string]struct{}
+	disposable map[string]struct{}
+}
+
+// NewPolicy creates a policy accepting only the allowed domains, if any, and
+// rejecting the denied ones. When blockDisposable is set, the embedded list
+// of disposable domains and extraDisposable are rejected as well.
+func NewPolicy(allowed, denied []string, blockDisposable bool, extraDisposable []string) *Policy {
+	p := &Policy{
+		allowed: toSet(allowed),
+		denied:  toSet(denied),
+	}
+
+	if blockDisposable {
+		p.disposable = toSet(append(Parse(embeddedDisposable), extraDisposable...))
+	}
+
+	return p
+}
+
+// Check returns a *RejectedError if the domain of email is not accepted.
+// The address itself should already be validated.
+func (p *Policy) Check(email string) error {
+	at := strings.LastIndexByte(email, '@')
+	domain := strings.ToLower(email[at+1:])
+
+	if len(p.allowed) > 0 && !matches(p.allowed, domain) {
+		return errNotAllowed
+	}
+
+	if matches(p.denied, domain) {
+		return errNotAllowed
+	}
+
+	if matches(p.disposable, domain) {
+		return errDisposable
+	}
+
+	return nil
+}
+
+// Parse reads a domain list with one domain per line, skipping blank lines
+// and lines starting with #.
+func Parse(list string) []string {
+	var domains []string
+
+	for line := range strings.Lines(list) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		domains = append(domains, line)
+	}
+
+	return domains
+}
+
+// matches reports whether domain or any of its parent domains is in set, so
+// that listing example.com also covers mail.example.com.
+func matches(set map[string]struct{}, domain string) bool {
+	for domain != "" {
+		if _, ok := set[domain]; ok {
+			return true
+		}
+
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+
+	return false
+}
+
+func toSet(domains []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			set[domain] = struct{}{}
+		}
+	}
+
+	return set
+}
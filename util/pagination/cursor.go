@@ -0,0 +1,76 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Cursor points at the last item of a page: the value of the field the page
+// is sorted by and the item's _id, which breaks ties. IDs are UUIDv7, so
+// items created in the same millisecond still come back in creation order.
+// Values are kept as raw BSON so dates and strings survive the round trip
+// through the client with their original types.
+type Cursor struct {
+	Field string        `bson:"f"`
+	Value bson.RawValue `bson:"v"`
+	ID    bson.RawValue `bson:"id"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// secret signs cursors, since their values end up in queries. It is random
+// until SetSecret is called, which only works for a single instance and
+// until it restarts.
+var secret atomic.Pointer[[]byte]
+
+func init() {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	secret.Store(&key)
+}
+
+// SetSecret sets the key cursors are signed with. It must be the same on
+// every instance.
+func SetSecret(key []byte) {
+	secret.Store(&key)
+}
+
+// Encode returns the opaque form handed to clients.
+func (c Cursor) Encode() string {
+	data, _ := bson.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data) + "." + sign(data)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var cursor Cursor
+
+	encoded, signature, ok := strings.Cut(s, ".")
+	if !ok {
+		return cursor, errInvalidCursor
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(sign(data))) {
+		return cursor, errInvalidCursor
+	}
+
+	err = bson.Unmarshal(data, &cursor)
+	if err != nil || cursor.Field == "" || cursor.ID.Type != bson.TypeString {
+		return cursor, errInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+func sign(data []byte) string {
+	mac := hmac.New(sha256.New, *secret.Load())
+	mac.Write(data)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
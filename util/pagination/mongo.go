@@ -0,0 +1,103 @@
+package pagination
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/BurakYs/go-api-example/httperror"
+)
+
+// Sort orders a page by a single field, with _id as the tie breaker. The
+// collection should have a compound index on {Field, _id} in this order.
+// Type is the BSON type of Field, which cursor values must have.
+type Sort struct {
+	Field      string
+	Type       bson.Type
+	Descending bool
+}
+
+var NewestFirst = Sort{Field: "created_at", Type: bson.TypeDateTime, Descending: true}
+
+var (
+	ErrCursorMismatch   = httperror.New(fiber.StatusBadRequest, "Cursor does not match the requested sort order")
+	ErrSortFieldMissing = errors.New("sorted documents must have the sort field")
+)
+
+// Find returns one page of documents matching filter along with the cursor
+// of the next page, or an empty string on the last page.
+func Find[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, query Query, sort Sort) ([]*T, string, error) {
+	direction, operator := 1, "$gt"
+	if sort.Descending {
+		direction, operator = -1, "$lt"
+	}
+
+	if after := query.After(); after != nil {
+		if after.Field != sort.Field || after.Value.Type != sort.Type {
+			return nil, "", ErrCursorMismatch
+		}
+
+		filter = bson.M{
+			"$and": bson.A{
+				filter,
+				bson.M{"$or": bson.A{
+					bson.M{sort.Field: bson.M{operator: after.Value}},
+					bson.M{sort.Field: after.Value, "_id": bson.M{operator: after.ID}},
+				}},
+			},
+		}
+	}
+
+	limit := query.PageSize()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: sort.Field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(limit + 1))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var docs []bson.Raw
+	err = cursor.All(ctx, &docs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(docs) > limit {
+		docs = docs[:limit]
+
+		// A document without the field, or with a value of another type,
+		// would produce a cursor the next request rejects, so fail here
+		// rather than handing it out.
+		last := docs[limit-1]
+		value, err := last.LookupErr(sort.Field)
+		if err != nil || value.Type != sort.Type {
+			return nil, "", ErrSortFieldMissing
+		}
+
+		next = Cursor{
+			Field: sort.Field,
+			Value: value,
+			ID:    last.Lookup("_id"),
+		}.Encode()
+	}
+
+	items := make([]*T, len(docs))
+	for i, doc := range docs {
+		items[i] = new(T)
+
+		err = bson.Unmarshal(doc, items[i])
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return items, next, nil
+}
@@ -0,0 +1,72 @@
+package pagination
+
+import (
+	"net/url"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const DefaultLimit = 20
+
+// Query is meant to be embedded in list request DTOs bound with
+// middleware.ValidateQuery.
+type Query struct {
+	Cursor string `query:"cursor" validate:"omitempty,cursor"`
+	Limit  int    `query:"limit"  validate:"omitempty,min=1,max=100"`
+}
+
+func (q Query) PageSize() int {
+	if q.Limit == 0 {
+		return DefaultLimit
+	}
+
+	return q.Limit
+}
+
+// After returns the decoded cursor, or nil for the first page. The cursor is
+// checked during validation, so decoding errors are not expected here.
+func (q Query) After() *Cursor {
+	if q.Cursor == "" {
+		return nil
+	}
+
+	cursor, err := DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil
+	}
+
+	return &cursor
+}
+
+type Response[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+func NewResponse[T any](data []T, nextCursor string) Response[T] {
+	return Response[T]{
+		Data:       data,
+		NextCursor: nextCursor,
+		HasMore:    nextCursor != "",
+	}
+}
+
+// SetLinkHeader adds a Link header pointing at the next page, keeping every
+// other query parameter of the current request.
+func SetLinkHeader(c fiber.Ctx, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+
+	u, err := url.Parse(c.OriginalURL())
+	if err != nil {
+		return
+	}
+
+	query := u.Query()
+	query.Set("cursor", nextCursor)
+	u.RawQuery = query.Encode()
+
+	c.Set(fiber.HeaderLink, "<"+c.BaseURL()+u.String()+`>; rel="next"`)
+}
@@ -0,0 +1,58 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedList looks up passwords in a local copy of the Pwned Passwords
+// range files. The directory contains one file per 5 character SHA-1 prefix
+// (e.g. 21BD1.txt), each line holding the remaining hash suffix and a count
+// separated by a colon, as produced by the official downloader. Only the file
+// for the password's prefix is read, mirroring the k-anonymity range API
+// without any network access.
+type BreachedList struct {
+	dir string
+}
+
+func NewBreachedList(dir string) *BreachedList {
+	return &BreachedList{
+		dir: dir,
+	}
+}
+
+func (b *BreachedList) Contains(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(b.dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+
+		candidate, count, _ := strings.Cut(scanner.Text(), ":")
+		if strings.EqualFold(candidate, suffix) && strings.TrimSpace(count) != "0" {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,25 @@
+package password
+
+import "golang.org/x/text/secure/precis"
+
+// Normalize prepares a password for hashing with the PRECIS OpaqueString
+// profile (RFC 8265), the successor of SASLprep: non-ASCII spaces are mapped
+// to ASCII spaces and the result is Unicode normalized, so the same secret
+// typed on different keyboards hashes the same way. Surrounding whitespace is
+// kept. Passwords the profile rejects are returned unchanged so validation
+// can report them.
+func Normalize(password string) string {
+	normalized, err := precis.OpaqueString.String(password)
+	if err != nil {
+		return password
+	}
+
+	return normalized
+}
+
+// IsValid reports whether the password only contains characters allowed by
+// the OpaqueString profile.
+func IsValid(password string) bool {
+	_, err := precis.OpaqueString.String(password)
+	return err == nil
+}
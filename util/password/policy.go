@@ -0,0 +1,87 @@
+package password
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	zxcvbn "github.com/nbutton23/zxcvbn-go"
+)
+
+type Policy struct {
+	MinScore         int
+	MinCharClasses   int
+	DisallowUserInfo bool
+	Breached         *BreachedList
+}
+
+// Check returns a message for every rule the password violates. userInputs
+// are values such as the user's name and email which the password should not
+// be derived from.
+func (p *Policy) Check(ctx context.Context, password string, userInputs ...string) ([]string, error) {
+	var failures []string
+
+	if p.MinCharClasses > 0 && countCharClasses(password) < p.MinCharClasses {
+		failures = append(failures, fmt.Sprintf("This field must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", p.MinCharClasses))
+	}
+
+	if p.DisallowUserInfo && containsUserInfo(password, userInputs) {
+		failures = append(failures, "This field must not contain your name or email")
+	}
+
+	if p.MinScore > 0 && zxcvbn.PasswordStrength(password, userInputs).Score < p.MinScore {
+		failures = append(failures, "This password is too easy to guess")
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(ctx, password)
+		if err != nil {
+			return nil, err
+		}
+
+		if breached {
+			failures = append(failures, "This password has appeared in a data breach, please choose a different one")
+		}
+	}
+
+	return failures, nil
+}
+
+func countCharClasses(password string) int {
+	var lower, upper, digit, symbol int
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
+
+func containsUserInfo(password string, userInputs []string) bool {
+	password = strings.ToLower(password)
+
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		if local, _, ok := strings.Cut(input, "@"); ok {
+			input = local
+		}
+
+		for _, part := range strings.Fields(input) {
+			if len(part) >= 3 && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
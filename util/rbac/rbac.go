@@ -0,0 +1,52 @@
+package rbac
+
+import (
+	"slices"
+	"strings"
+)
+
+const Wildcard = "*"
+
+type RBAC struct {
+	roles map[string][]string
+}
+
+func New(roles map[string][]string) *RBAC {
+	return &RBAC{
+		roles: roles,
+	}
+}
+
+// Permissions returns the permissions granted by the given roles. Unknown
+// roles grant nothing.
+func (r *RBAC) Permissions(roles []string) []string {
+	var permissions []string
+
+	for _, role := range roles {
+		for _, permission := range r.roles[role] {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	return permissions
+}
+
+// HasPermission reports whether permissions grant the required permission.
+// "*" grants everything and "users:*" grants every permission in the users
+// namespace.
+func HasPermission(permissions []string, required string) bool {
+	for _, permission := range permissions {
+		if permission == Wildcard || permission == required {
+			return true
+		}
+
+		namespace, ok := strings.CutSuffix(permission, ":"+Wildcard)
+		if ok && strings.HasPrefix(required, namespace+":") {
+			return true
+		}
+	}
+
+	return false
+}
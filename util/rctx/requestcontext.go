@@ -3,8 +3,17 @@ package rctx
 import "github.com/gofiber/fiber/v3"
 
 const (
-	UserIDKey    = "userID"
-	SessionIDKey = "sessionID"
+	UserIDKey           = "userID"
+	SessionIDKey        = "sessionID"
+	RolesKey            = "roles"
+	PlanKey             = "plan"
+	PermissionsKey      = "permissions"
+	ImpersonatorIDKey   = "impersonatorID"
+	OrganizationIDKey   = "organizationID"
+	OrganizationRoleKey = "organizationRole"
+	TenantIDKey         = "tenantID"
+	ClientIPKey         = "clientIP"
+	IPAllowedKey        = "ipAllowed"
 )
 
 func SetUserID(c fiber.Ctx, userID string) {
@@ -32,3 +41,106 @@ func GetSessionID(c fiber.Ctx) string {
 
 	return id
 }
+
+func SetRoles(c fiber.Ctx, roles []string) {
+	c.Locals(RolesKey, roles)
+}
+
+func GetRoles(c fiber.Ctx) []string {
+	roles, _ := c.Locals(RolesKey).([]string)
+	return roles
+}
+
+func SetPermissions(c fiber.Ctx, permissions []string) {
+	c.Locals(PermissionsKey, permissions)
+}
+
+func GetPermissions(c fiber.Ctx) []string {
+	permissions, _ := c.Locals(PermissionsKey).([]string)
+	return permissions
+}
+
+func SetPlan(c fiber.Ctx, plan string) {
+	c.Locals(PlanKey, plan)
+}
+
+// GetPlan returns an empty string for users on the default plan and for
+// unauthenticated requests.
+func GetPlan(c fiber.Ctx) string {
+	plan, _ := c.Locals(PlanKey).(string)
+	return plan
+}
+
+// SetImpersonatorID stores the real admin behind an impersonation session.
+func SetImpersonatorID(c fiber.Ctx, impersonatorID string) {
+	c.Locals(ImpersonatorIDKey, impersonatorID)
+}
+
+// GetImpersonatorID returns an empty string unless the request is made
+// through an impersonation session.
+func GetImpersonatorID(c fiber.Ctx) string {
+	id, _ := c.Locals(ImpersonatorIDKey).(string)
+	return id
+}
+
+func SetOrganization(c fiber.Ctx, organizationID, role string) {
+	c.Locals(OrganizationIDKey, organizationID)
+	c.Locals(OrganizationRoleKey, role)
+}
+
+func GetOrganizationID(c fiber.Ctx) string {
+	id, ok := c.Locals(OrganizationIDKey).(string)
+	if !ok {
+		panic("organizationID not set in context")
+	}
+
+	return id
+}
+
+func GetOrganizationRole(c fiber.Ctx) string {
+	role, ok := c.Locals(OrganizationRoleKey).(string)
+	if !ok {
+		panic("organizationRole not set in context")
+	}
+
+	return role
+}
+
+func SetTenantID(c fiber.Ctx, tenantID string) {
+	c.Locals(TenantIDKey, tenantID)
+}
+
+func GetTenantID(c fiber.Ctx) string {
+	id, ok := c.Locals(TenantIDKey).(string)
+	if !ok {
+		panic("tenantID not set in context")
+	}
+
+	return id
+}
+
+func SetClientIP(c fiber.Ctx, ip string) {
+	c.Locals(ClientIPKey, ip)
+}
+
+// GetClientIP returns the IP resolved by the ClientIP middleware, falling
+// back to the connection's IP for requests that never reached it.
+func GetClientIP(c fiber.Ctx) string {
+	ip, ok := c.Locals(ClientIPKey).(string)
+	if !ok {
+		return c.IP()
+	}
+
+	return ip
+}
+
+// SetIPAllowed marks requests from IPs on the allow list, which skip IP
+// based rate limits.
+func SetIPAllowed(c fiber.Ctx, allowed bool) {
+	c.Locals(IPAllowedKey, allowed)
+}
+
+func IsIPAllowed(c fiber.Ctx) bool {
+	allowed, _ := c.Locals(IPAllowedKey).(bool)
+	return allowed
+}
@@ -0,0 +1,67 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/BurakYs/go-api-example/util/rctx"
+)
+
+type contextKey struct{}
+
+var ErrMissing = errors.New("tenant not set in context")
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// IsValid reports whether id can be used as a tenant ID.
+func IsValid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// NewContext returns a copy of ctx carrying the tenant ID, for code running
+// outside of a request such as background jobs.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant stored by NewContext or, when ctx is a
+// request context, the one resolved by the tenant middleware.
+func FromContext(ctx context.Context) (string, bool) {
+	if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+		return id, true
+	}
+
+	id, ok := ctx.Value(rctx.TenantIDKey).(string)
+	return id, ok && id != ""
+}
+
+// ID is like FromContext but returns ErrMissing when no tenant is set, so
+// callers fail closed instead of touching data of every tenant.
+func ID(ctx context.Context) (string, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrMissing
+	}
+
+	return id, nil
+}
+
+// Scope restricts filter to the tenant in ctx. The filter is copied, so
+// callers may pass shared values.
+func Scope(ctx context.Context, filter bson.M) (bson.M, error) {
+	id, err := ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := make(bson.M, len(filter)+1)
+	for k, v := range filter {
+		scoped[k] = v
+	}
+	scoped["tenant_id"] = id
+
+	return scoped, nil
+}
@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 
 	govalidator "github.com/go-playground/validator/v10"
+
+	"github.com/BurakYs/go-api-example/util/displayname"
+	"github.com/BurakYs/go-api-example/util/pagination"
+	"github.com/BurakYs/go-api-example/util/password"
 )
 
 func New() *govalidator.Validate {
@@ -35,19 +40,74 @@ func New() *govalidator.Validate {
 		return alphaSpaceRegex.MatchString(fl.Field().String())
 	})
 
+	_ = validate.RegisterValidation("display_name", func(fl govalidator.FieldLevel) bool {
+		return displayname.IsValid(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("single_script", func(fl govalidator.FieldLevel) bool {
+		return displayname.IsSingleScript(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("password", func(fl govalidator.FieldLevel) bool {
+		return password.IsValid(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("max_bytes", func(fl govalidator.FieldLevel) bool {
+		limit, err := strconv.Atoi(fl.Param())
+		if err != nil {
+			return false
+		}
+
+		return len(fl.Field().String()) <= limit
+	})
+
+	_ = validate.RegisterValidation("cursor", func(fl govalidator.FieldLevel) bool {
+		_, err := pagination.DecodeCursor(fl.Field().String())
+		return err == nil
+	})
+
 	return validate
 }
 
 func GetErrorMessage(fieldError govalidator.FieldError) string {
 	switch fieldError.Tag() {
-	case "required":
+	case "required", "required_unless":
 		return "This field is required"
 	case "email":
 		return "This field must be a valid email address"
 	case "uuid":
 		return "This field must be a valid UUID"
+	case "hexadecimal":
+		return "This field must be a hexadecimal string"
+	case "ip":
+		return "This field must be a valid IP address"
+	case "ip|cidr":
+		return "This field must be a valid IP address or CIDR range"
+	case "len":
+		switch fieldError.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("This field must be exactly %s characters long", fieldError.Param())
+		case reflect.Slice, reflect.Array:
+			return fmt.Sprintf("This field must contain exactly %s items", fieldError.Param())
+		default:
+			return fmt.Sprintf("The value must be exactly %s", fieldError.Param())
+		}
+	case "oneof":
+		return fmt.Sprintf("This field must be one of: %s", strings.ReplaceAll(fieldError.Param(), " ", ", "))
+	case "cursor":
+		return "This field must be a cursor returned by a previous request"
+	case "datetime":
+		return fmt.Sprintf("This field must be a date in the format %s", fieldError.Param())
 	case "alpha_space":
 		return "This field can only contain alphabetic and space characters"
+	case "display_name":
+		return "This field can only contain letters separated by spaces, apostrophes or hyphens"
+	case "single_script":
+		return "This field must not mix letters from different scripts"
+	case "password":
+		return "This field contains characters that are not allowed in passwords"
+	case "max_bytes":
+		return fmt.Sprintf("This field must be at most %s bytes long", fieldError.Param())
 	case "min":
 		switch fieldError.Kind() {
 		case reflect.String: